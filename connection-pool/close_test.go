@@ -0,0 +1,125 @@
+package connection_pool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockPool(t *testing.T, cfg Config[*mockConn]) *Pool[*mockConn] {
+	var id atomic.Int32
+	if cfg.Factory == nil {
+		cfg.Factory = func(context.Context) (*mockConn, error) {
+			return &mockConn{id: id.Add(1)}, nil
+		}
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+	return p
+}
+
+func TestPool_Close(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{MaxCount: 2, MaxIdleCount: 2})
+
+	// 借出两个之后，第三个请求进入等待队列
+	c1, err := p.Get(context.Background())
+	require.NoError(t, err)
+	c2, err := p.Get(context.Background())
+	require.NoError(t, err)
+	waitErr := make(chan error, 1)
+	go func() {
+		_, err := p.Get(context.Background())
+		waitErr <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	closeErr := make(chan error, 1)
+	go func() {
+		closeErr <- p.Close(context.Background())
+	}()
+
+	// 等待的请求被唤醒
+	select {
+	case err := <-waitErr:
+		assert.Equal(t, PoolClosedErr, err)
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken up")
+	}
+	_, err = p.Get(context.Background())
+	assert.Equal(t, PoolClosedErr, err)
+
+	// 借出的连接没有放回之前 Close 一直等待
+	require.NoError(t, p.Put(context.Background(), c1))
+	select {
+	case <-closeErr:
+		t.Fatal("close returned before all connections were put back")
+	case <-time.After(20 * time.Millisecond):
+	}
+	require.NoError(t, p.Put(context.Background(), c2))
+	require.NoError(t, <-closeErr)
+	assert.True(t, c1.closed.Load())
+	assert.True(t, c2.closed.Load())
+}
+
+func TestPool_CloseTimeout(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{MaxCount: 2, MaxIdleCount: 2, InitCount: 2})
+	c, err := p.Get(context.Background())
+	require.NoError(t, err)
+	idle := (<-p.idleConns).c
+	p.idleConns <- &idleConn[*mockConn]{c: idle, lastActiveTime: time.Now()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.Close(ctx))
+	// 即使超时，空闲连接也已经被销毁
+	assert.True(t, idle.closed.Load())
+	assert.False(t, c.closed.Load())
+
+	// 超时之后放回的连接依然会被销毁
+	require.NoError(t, p.Put(context.Background(), c))
+	assert.True(t, c.closed.Load())
+	assert.NoError(t, p.Close(context.Background()))
+}
+
+func TestPool_CloseCancelledWithEvictor(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{
+		MaxCount: 2, MaxIdleCount: 2, InitCount: 2, EvictInterval: time.Millisecond,
+	})
+	idle := []*mockConn{(<-p.idleConns).c, (<-p.idleConns).c}
+	for _, c := range idle {
+		p.idleConns <- &idleConn[*mockConn]{c: c, lastActiveTime: time.Now()}
+	}
+
+	// ctx 已经取消，依然会销毁空闲连接
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, p.Close(ctx))
+	for _, c := range idle {
+		assert.True(t, c.closed.Load())
+	}
+	assert.Equal(t, 0, p.Stats().Open)
+}
+
+func TestPool_GetCloseRace(t *testing.T) {
+	var dialed atomic.Int32
+	p := newMockPool(t, Config[*mockConn]{
+		MaxCount:     1,
+		MaxIdleCount: 1,
+		Factory: func(context.Context) (*mockConn, error) {
+			dialed.Add(1)
+			return &mockConn{}, nil
+		},
+	})
+	// Get 通过了 stop 的检查之后，加锁之前连接池被关闭
+	p.beforeLock = func() {
+		require.NoError(t, p.Close(context.Background()))
+	}
+	_, err := p.Get(context.Background())
+	assert.Equal(t, PoolClosedErr, err)
+	assert.Equal(t, int32(0), dialed.Load())
+	assert.Equal(t, 0, p.Stats().InUse)
+}
@@ -0,0 +1,148 @@
+package connection_pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultTestOnBorrowInterval NewPool 中空闲超过这个时间的连接在取出时才会检查
+const defaultTestOnBorrowInterval = time.Second
+
+var ConnReleasedErr = errors.New("connection_pool: connection already returned to the pool")
+
+// ConnPool net.Conn 的连接池，Get 返回的 PooledConn 调用 Close 即可放回
+type ConnPool struct {
+	pool *Pool[net.Conn]
+}
+
+// NewPool 创建 net.Conn 的连接池，空闲超过 1s 的连接在取出时会通过 PingConn 检查是否已经被对端关闭
+func NewPool(
+	maxCount int,
+	maxIdleCount int,
+	maxIdleTime time.Duration,
+	initCount int,
+	factory func(ctx context.Context) (net.Conn, error),
+) (*ConnPool, error) {
+	return NewConnPool(Config[net.Conn]{
+		MaxCount:     maxCount,
+		MaxIdleCount: maxIdleCount,
+		MaxIdleTime:  maxIdleTime,
+		InitCount:    initCount,
+		Factory:      factory,
+		Validate:     PingConn,
+
+		TestOnBorrowInterval: defaultTestOnBorrowInterval,
+	})
+}
+
+// NewConnPool 使用完整的配置创建 net.Conn 的连接池，例如需要设置 MaxLifetime、后台清理时
+func NewConnPool(cfg Config[net.Conn]) (*ConnPool, error) {
+	p, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ConnPool{pool: p}, nil
+}
+
+func (p *ConnPool) Get(ctx context.Context) (*PooledConn, error) {
+	c, err := p.pool.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PooledConn{Conn: c, pool: p.pool}, nil
+}
+
+// Close 关闭连接池，语义同 Pool.Close
+func (p *ConnPool) Close(ctx context.Context) error {
+	return p.pool.Close(ctx)
+}
+
+// PooledConn 从连接池中借出的连接，Close 时放回连接池而不是关闭底层连接。
+// 出现 I/O 错误之后应该调用 MarkUnusable 或者 Discard，避免坏掉的连接被下一个请求拿到
+type PooledConn struct {
+	net.Conn
+	pool *Pool[net.Conn]
+
+	mu       sync.Mutex
+	unusable bool
+	released bool // 已经放回或者销毁，之后的读写、设置超时和 Close 都返回错误
+}
+
+// MarkUnusable 标记连接不可用，Close 时销毁而不是放回
+func (c *PooledConn) MarkUnusable() {
+	c.mu.Lock()
+	c.unusable = true
+	c.mu.Unlock()
+}
+
+// Discard 销毁连接，等价于 MarkUnusable 之后 Close
+func (c *PooledConn) Discard() error {
+	c.MarkUnusable()
+	return c.Close()
+}
+
+// Close 将连接放回连接池，重复调用返回 ConnReleasedErr，不会重复放回
+func (c *PooledConn) Close() error {
+	c.mu.Lock()
+	if c.released {
+		c.mu.Unlock()
+		return ConnReleasedErr
+	}
+	c.released = true
+	unusable := c.unusable
+	c.mu.Unlock()
+
+	if unusable {
+		return c.pool.Discard(context.Background(), c.Conn)
+	}
+	return c.pool.Put(context.Background(), c.Conn)
+}
+
+func (c *PooledConn) Read(b []byte) (int, error) {
+	if c.isReleased() {
+		return 0, ConnReleasedErr
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *PooledConn) Write(b []byte) (int, error) {
+	if c.isReleased() {
+		return 0, ConnReleasedErr
+	}
+	return c.Conn.Write(b)
+}
+
+// SetDeadline 放回之后底层连接可能已经被其他请求借出，不能再修改
+func (c *PooledConn) SetDeadline(t time.Time) error {
+	if c.isReleased() {
+		return ConnReleasedErr
+	}
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *PooledConn) SetReadDeadline(t time.Time) error {
+	if c.isReleased() {
+		return ConnReleasedErr
+	}
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *PooledConn) SetWriteDeadline(t time.Time) error {
+	if c.isReleased() {
+		return ConnReleasedErr
+	}
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *PooledConn) isReleased() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.released
+}
+
+func (p *ConnPool) Stats() Stats {
+	return p.pool.Stats()
+}
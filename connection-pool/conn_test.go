@@ -0,0 +1,76 @@
+package connection_pool
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPooledConn(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	peers := make(chan net.Conn, 4)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			peers <- c
+		}
+	}()
+
+	p, err := NewPool(1, 1, time.Minute, 0, func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", l.Addr().String())
+	})
+	require.NoError(t, err)
+
+	c1, err := p.Get(context.Background())
+	require.NoError(t, err)
+	peer := <-peers
+	defer peer.Close()
+
+	got := make(chan *PooledConn, 1)
+	go func() {
+		c, err := p.Get(context.Background())
+		assert.NoError(t, err)
+		got <- c
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// Close 放回连接池，交给等待的请求，底层连接没有关闭
+	require.NoError(t, c1.Close())
+	c2 := <-got
+	assert.Same(t, c1.Conn, c2.Conn)
+
+	// 重复放回、放回之后读写和设置超时都返回错误
+	assert.Equal(t, ConnReleasedErr, c1.Close())
+	_, err = c1.Write([]byte("x"))
+	assert.Equal(t, ConnReleasedErr, err)
+	past := time.Now().Add(-time.Second)
+	assert.Equal(t, ConnReleasedErr, c1.SetDeadline(past))
+	assert.Equal(t, ConnReleasedErr, c1.SetReadDeadline(past))
+	assert.Equal(t, ConnReleasedErr, c1.SetWriteDeadline(past))
+	// 超时没有作用到已经被 c2 借出的底层连接上
+	_, err = c2.Write([]byte("x"))
+	require.NoError(t, err)
+
+	// 标记为不可用之后 Close 会销毁底层连接
+	c2.MarkUnusable()
+	require.NoError(t, c2.Close())
+	buf := make([]byte, 2)
+	n, _ := peer.Read(buf)
+	assert.Equal(t, 1, n)
+	_, err = peer.Read(buf)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, ConnReleasedErr, c2.Discard())
+
+	require.NoError(t, p.Close(context.Background()))
+}
@@ -0,0 +1,137 @@
+package connection_pool
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDialBackoff     = 10 * time.Millisecond
+	defaultDialMaxBackoff  = time.Second
+	defaultBreakerCooldown = time.Second
+)
+
+var CircuitOpenErr = errors.New("connection_pool: too many dial failures, circuit breaker is open")
+
+// create 调用 factory 创建连接并计数，不修改 currCount。
+// 失败时按照指数退避重试，连续失败太多次之后熔断，熔断期间直接返回 CircuitOpenErr
+func (p *Pool[T]) create(ctx context.Context) (T, error) {
+	var zero T
+	if !p.breaker.allow() {
+		return zero, CircuitOpenErr
+	}
+	c, err := p.dial(ctx)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			// 调用方主动取消，不能说明后端是否可用。
+			// 而超过调用方的截止时间依然计为失败，后端丢弃 SYN 时连接会一直挂起，正是需要熔断的场景
+			p.breaker.release()
+		} else {
+			p.breaker.report(false)
+		}
+		return zero, err
+	}
+	p.breaker.report(true)
+	atomic.AddInt64(&p.stats.created, 1)
+	return c, nil
+}
+
+func (p *Pool[T]) dial(ctx context.Context) (T, error) {
+	for attempt := 0; ; attempt++ {
+		c, err := p.dialOnce(ctx)
+		if err == nil {
+			return c, nil
+		}
+		atomic.AddInt64(&p.stats.factoryErrors, 1)
+		if attempt >= p.dialRetries || ctx.Err() != nil {
+			return c, err
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return c, ctx.Err()
+		}
+	}
+}
+
+func (p *Pool[T]) dialOnce(ctx context.Context) (T, error) {
+	if p.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.dialTimeout)
+		defer cancel()
+	}
+	return p.factory(ctx)
+}
+
+// backoff 第 attempt 次重试前等待的时间，每次翻倍，并在 [d/2, d] 之间随机，避免大量请求同时重连
+func (p *Pool[T]) backoff(attempt int) time.Duration {
+	d := p.dialBackoff
+	for i := 0; i < attempt && d < p.dialMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.dialMaxBackoff {
+		d = p.dialMaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// breaker 连续失败 threshold 次之后熔断，cooldown 之后只放行一个请求试探，成功则恢复，失败则继续熔断
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	lock      sync.Mutex
+	failures  int       // 连续失败的次数
+	openUntil time.Time // 熔断结束的时间
+	probing   bool      // 已经有请求在试探
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// release 结果不计入统计，只是结束试探，让下一个请求可以继续试探
+func (b *breaker) release() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.lock.Lock()
+	b.probing = false
+	b.lock.Unlock()
+}
+
+func (b *breaker) report(ok bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
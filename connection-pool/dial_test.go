@@ -0,0 +1,215 @@
+package connection_pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingFactory 一直阻塞到 ctx 结束，模拟连不上的后端
+func blockingFactory(ctx context.Context) (*mockConn, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestPool_DialContext(t *testing.T) {
+	t.Run("get deadline", func(t *testing.T) {
+		p := newMockPool(t, Config[*mockConn]{MaxCount: 1, Factory: blockingFactory})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := p.Get(ctx)
+		assert.Equal(t, context.DeadlineExceeded, err)
+		// 名额已经归还
+		assert.Equal(t, 0, p.Stats().Open)
+	})
+
+	t.Run("dial timeout", func(t *testing.T) {
+		p := newMockPool(t, Config[*mockConn]{
+			MaxCount: 1, Factory: blockingFactory, DialTimeout: 10 * time.Millisecond,
+		})
+		_, err := p.Get(context.Background())
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
+
+func TestPool_DialRetry(t *testing.T) {
+	testCases := []struct {
+		name      string
+		failures  int32
+		retries   int
+		wantErr   bool
+		wantCalls int32
+	}{
+		{name: "no retry", failures: 1, wantErr: true, wantCalls: 1},
+		{name: "succeed after retry", failures: 2, retries: 2, wantCalls: 3},
+		{name: "retries exhausted", failures: 3, retries: 1, wantErr: true, wantCalls: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			p := newMockPool(t, Config[*mockConn]{
+				MaxCount: 1,
+				Factory: func(ctx context.Context) (*mockConn, error) {
+					if calls.Add(1) <= tc.failures {
+						return nil, errors.New("dial failed")
+					}
+					return &mockConn{}, nil
+				},
+				DialRetries: tc.retries,
+				DialBackoff: time.Millisecond,
+			})
+			_, err := p.Get(context.Background())
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.wantCalls, calls.Load())
+			assert.Equal(t, int64(min(tc.failures, tc.wantCalls)), p.Stats().FactoryErrors)
+		})
+	}
+}
+
+func TestPool_Backoff(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{
+		MaxCount: 1, DialBackoff: 10 * time.Millisecond, DialMaxBackoff: 100 * time.Millisecond,
+	})
+	for attempt := 0; attempt < 70; attempt++ {
+		d := 10 * time.Millisecond << attempt
+		if attempt >= 4 {
+			d = 100 * time.Millisecond
+		}
+		got := p.backoff(attempt)
+		assert.GreaterOrEqual(t, got, d/2)
+		assert.LessOrEqual(t, got, d)
+	}
+}
+
+func TestPool_Breaker(t *testing.T) {
+	var calls atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	p := newMockPool(t, Config[*mockConn]{
+		MaxCount: 1,
+		Factory: func(ctx context.Context) (*mockConn, error) {
+			calls.Add(1)
+			if fail.Load() {
+				return nil, errors.New("dial failed")
+			}
+			return &mockConn{}, nil
+		},
+		BreakerThreshold: 2,
+		BreakerCooldown:  30 * time.Millisecond,
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := p.Get(context.Background())
+		assert.EqualError(t, err, "dial failed")
+	}
+	// 熔断之后不再调用 factory
+	_, err := p.Get(context.Background())
+	assert.Equal(t, CircuitOpenErr, err)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// 冷却之后试探失败，继续熔断
+	time.Sleep(40 * time.Millisecond)
+	_, err = p.Get(context.Background())
+	assert.EqualError(t, err, "dial failed")
+	_, err = p.Get(context.Background())
+	assert.Equal(t, CircuitOpenErr, err)
+
+	// 试探成功之后恢复
+	time.Sleep(40 * time.Millisecond)
+	fail.Store(false)
+	_, err = p.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	b := &breaker{threshold: 1, cooldown: time.Millisecond}
+	assert.True(t, b.allow())
+	b.report(false)
+	assert.False(t, b.allow())
+
+	time.Sleep(2 * time.Millisecond)
+	// 冷却之后只放行一个请求试探
+	assert.True(t, b.allow())
+	assert.False(t, b.allow())
+	b.report(true)
+	assert.True(t, b.allow())
+	assert.True(t, b.allow())
+}
+
+func TestPool_BreakerHangingDial(t *testing.T) {
+	var calls atomic.Int32
+	p := newMockPool(t, Config[*mockConn]{
+		MaxCount: 1,
+		Factory: func(ctx context.Context) (*mockConn, error) {
+			calls.Add(1)
+			return blockingFactory(ctx)
+		},
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	})
+
+	// 后端一直不响应，直到 Get 超时，同样计为失败
+	var opened int
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		_, err := p.Get(ctx)
+		cancel()
+		if err == CircuitOpenErr {
+			opened++
+		} else {
+			assert.Equal(t, context.DeadlineExceeded, err)
+		}
+	}
+	assert.Equal(t, 3, opened)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestPool_BreakerCancelledDial(t *testing.T) {
+	var hang atomic.Bool
+	p := newMockPool(t, Config[*mockConn]{
+		MaxCount: 1,
+		Factory: func(ctx context.Context) (*mockConn, error) {
+			if hang.Load() {
+				return blockingFactory(ctx)
+			}
+			return nil, errors.New("dial failed")
+		},
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Minute,
+	})
+	getCancelled := func() {
+		hang.Store(true)
+		defer hang.Store(false)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(5*time.Millisecond, cancel)
+		_, err := p.Get(ctx)
+		assert.Equal(t, context.Canceled, err)
+	}
+
+	_, err := p.Get(context.Background())
+	assert.EqualError(t, err, "dial failed")
+	// 调用方主动取消既不计为失败，也不会清空之前的失败次数
+	getCancelled()
+	_, err = p.Get(context.Background())
+	assert.EqualError(t, err, "dial failed")
+	_, err = p.Get(context.Background())
+	assert.Equal(t, CircuitOpenErr, err)
+}
+
+func TestBreaker_ReleaseProbe(t *testing.T) {
+	b := &breaker{threshold: 1, cooldown: time.Millisecond}
+	b.report(false)
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, b.allow())
+	assert.False(t, b.allow())
+	// 试探被取消之后，下一个请求可以继续试探，熔断状态不变
+	b.release()
+	assert.True(t, b.allow())
+	assert.Equal(t, 1, b.failures)
+}
@@ -0,0 +1,60 @@
+package connection_pool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockConn struct {
+	id     int32
+	closed atomic.Bool
+}
+
+func (m *mockConn) Close() error {
+	m.closed.Store(true)
+	return nil
+}
+
+func TestPool_Evict(t *testing.T) {
+	var created atomic.Int32
+	p, err := New(Config[*mockConn]{
+		MaxCount:      4,
+		MaxIdleCount:  4,
+		MaxIdleTime:   50 * time.Millisecond,
+		InitCount:     3,
+		MinIdle:       2,
+		EvictInterval: 10 * time.Millisecond,
+		Factory: func(context.Context) (*mockConn, error) {
+			return &mockConn{id: created.Add(1)}, nil
+		},
+	})
+	require.NoError(t, err)
+	initial := []*mockConn{}
+	for len(initial) < 3 {
+		initial = append(initial, (<-p.idleConns).c)
+	}
+	for _, c := range initial {
+		p.idleConns <- &idleConn[*mockConn]{c: c, lastActiveTime: time.Now()}
+	}
+
+	// 过期之后，初始的连接全部被后台清理，并补充到 MinIdle
+	assert.Eventually(t, func() bool {
+		for _, c := range initial {
+			if !c.closed.Load() {
+				return false
+			}
+		}
+		return len(p.idleConns) >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, p.Close(context.Background()))
+	n := created.Load()
+	time.Sleep(100 * time.Millisecond)
+	// 关闭之后不再清理和补充
+	assert.Equal(t, n, created.Load())
+}
@@ -0,0 +1,106 @@
+package connection_pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// session 不是 net.Conn，也没有实现 io.Closer
+type session struct {
+	id     int
+	broken bool
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config[*session]{MaxCount: 1})
+	assert.Error(t, err)
+
+	var id int
+	var closed []int
+	p, err := New(Config[*session]{
+		MaxCount:     2,
+		MaxIdleCount: 1,
+		MaxIdleTime:  time.Minute,
+		Factory: func(ctx context.Context) (*session, error) {
+			id++
+			return &session{id: id}, nil
+		},
+		Close: func(s *session) error {
+			closed = append(closed, s.id)
+			return nil
+		},
+		Validate: func(s *session) error {
+			if s.broken {
+				return errors.New("broken")
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	s1, err := p.Get(context.Background())
+	require.NoError(t, err)
+	s2, err := p.Get(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, p.Put(context.Background(), s1))
+	// 空闲连接已满，通过 Close 销毁
+	require.NoError(t, p.Put(context.Background(), s2))
+	assert.Equal(t, []int{2}, closed)
+
+	// TestOnBorrowInterval 为 0 时每次取出都校验，不可用的被销毁之后新建
+	s1.broken = true
+	s, err := p.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, s.id)
+	assert.Equal(t, []int{2, 1}, closed)
+	assert.Equal(t, int64(1), p.Stats().InvalidClosed)
+}
+
+func TestCloseIfCloser(t *testing.T) {
+	c := &mockConn{}
+	require.NoError(t, closeIfCloser(c))
+	assert.True(t, c.closed.Load())
+	// 没有实现 io.Closer 时什么都不做
+	assert.NoError(t, closeIfCloser(&session{}))
+	assert.NoError(t, closeIfCloser(42))
+}
+
+func TestNew_DefaultClose(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{MaxCount: 2, MaxIdleCount: 1})
+	c1, err := p.Get(context.Background())
+	require.NoError(t, err)
+	c2, err := p.Get(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, p.Put(context.Background(), c1))
+	// 没有配置 Close 时调用 io.Closer
+	require.NoError(t, p.Put(context.Background(), c2))
+	assert.True(t, c2.closed.Load())
+	assert.False(t, c1.closed.Load())
+}
+
+func TestNew_NoIdleTimeout(t *testing.T) {
+	var id int
+	p, err := New(Config[*session]{
+		MaxCount:     1,
+		MaxIdleCount: 1,
+		Factory: func(ctx context.Context) (*session, error) {
+			id++
+			return &session{id: id}, nil
+		},
+	})
+	require.NoError(t, err)
+
+	// MaxIdleTime 为 0 时空闲连接不会过期
+	s, err := p.Get(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, p.Put(context.Background(), s))
+	s, err = p.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.id)
+	assert.Equal(t, int64(0), p.Stats().MaxIdleTimeClosed)
+}
@@ -0,0 +1,394 @@
+package connection_pool
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xiaoyeshiyu/micro-tools/registry"
+)
+
+// KeyedConfig 按地址管理多个子连接池的配置
+type KeyedConfig[T any] struct {
+	// 每个子连接池的配置，MaxCount 即每个地址的连接数上限，其中的 Factory 不使用
+	Pool Config[T]
+	// 按照地址创建连接，必须
+	Factory func(ctx context.Context, addr string) (T, error)
+	// 所有地址的连接总数上限，包括空闲的连接，为 0 时不限制。
+	// 达到上限时优先销毁其他地址的空闲连接腾出名额，没有空闲连接时等待直到 ctx 超时
+	MaxTotal int
+	// 子连接池没有借出的连接，并且超过这个时间没有被使用时销毁，为 0 时不销毁
+	IdleTimeout time.Duration
+}
+
+// KeyedPool 每个地址一个子连接池，子连接池在第一次 Get 时创建，也可以通过 Watch 跟随注册中心创建和销毁
+type KeyedPool[T any] struct {
+	cfg     KeyedConfig[T]
+	close   func(T) error
+	sem     chan struct{} // 连接总数的名额，为 nil 时不限制
+	lock    sync.Mutex
+	pools   map[string]*keyedEntry[T]
+	watched map[string]map[string]struct{} // 每个服务通过 Watch 创建的地址
+	closed  bool
+
+	// 关闭之后通知后台的清理、Watch 退出，以及被移除的子连接池不再等待借出的连接
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type keyedEntry[T any] struct {
+	pool     *Pool[T]
+	lastUsed atomic.Int64 // 上次 Get 的时间，UnixNano
+}
+
+// KeyedConn 从 KeyedPool 借出的连接，记录了所属的子连接池，子连接池被移除之后依然可以放回，由子连接池销毁
+type KeyedConn[T any] struct {
+	Conn T
+	Addr string
+	pool *Pool[T]
+
+	released atomic.Bool
+}
+
+func NewKeyedPool[T any](cfg KeyedConfig[T]) (*KeyedPool[T], error) {
+	if cfg.Factory == nil {
+		return nil, errors.New("connection_pool: factory is nil")
+	}
+	k := &KeyedPool[T]{
+		cfg:     cfg,
+		close:   cfg.Pool.Close,
+		pools:   make(map[string]*keyedEntry[T]),
+		watched: make(map[string]map[string]struct{}),
+	}
+	if k.close == nil {
+		k.close = closeIfCloser[T]
+	}
+	if cfg.MaxTotal > 0 {
+		k.sem = make(chan struct{}, cfg.MaxTotal)
+	}
+	k.ctx, k.cancel = context.WithCancel(context.Background())
+	if cfg.IdleTimeout > 0 {
+		k.wg.Add(1)
+		go k.evictLoop()
+	}
+	return k, nil
+}
+
+func (k *KeyedPool[T]) Get(ctx context.Context, addr string) (*KeyedConn[T], error) {
+	for {
+		e, err := k.entry(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		c, err := e.pool.Get(ctx)
+		if errors.Is(err, PoolClosedErr) && !k.isClosed() {
+			// 子连接池刚好被移除，重新创建一个
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		e.lastUsed.Store(time.Now().UnixNano())
+		return &KeyedConn[T]{Conn: c, Addr: addr, pool: e.pool}, nil
+	}
+}
+
+// Put 放回所属的子连接池，重复调用返回 ConnReleasedErr
+func (c *KeyedConn[T]) Put(ctx context.Context) error {
+	if !c.released.CompareAndSwap(false, true) {
+		return ConnReleasedErr
+	}
+	return c.pool.Put(ctx, c.Conn)
+}
+
+// Discard 销毁连接，例如连接上出现了 I/O 错误
+func (c *KeyedConn[T]) Discard(ctx context.Context) error {
+	if !c.released.CompareAndSwap(false, true) {
+		return ConnReleasedErr
+	}
+	return c.pool.Discard(ctx, c.Conn)
+}
+
+// Keys 当前所有子连接池的地址，按照字典序排列
+func (k *KeyedPool[T]) Keys() []string {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	keys := make([]string, 0, len(k.pools))
+	for addr := range k.pools {
+		keys = append(keys, addr)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Stats 每个子连接池的统计信息
+func (k *KeyedPool[T]) Stats() map[string]Stats {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	res := make(map[string]Stats, len(k.pools))
+	for addr, e := range k.pools {
+		res[addr] = e.pool.Stats()
+	}
+	return res
+}
+
+// Remove 移除并关闭地址对应的子连接池，借出的连接放回时销毁
+func (k *KeyedPool[T]) Remove(addr string) {
+	k.lock.Lock()
+	e, ok := k.pools[addr]
+	delete(k.pools, addr)
+	k.lock.Unlock()
+	if ok {
+		k.retire(e.pool)
+	}
+}
+
+// Watch 订阅注册中心，服务实例上线时创建子连接池，下线时销毁。
+// 与 grpcResolver 一样，每次收到事件都重新拉取全部实例，timeout 是每次拉取的超时时间。
+// 首次拉取失败时返回错误，但是仍然会继续消费订阅的事件，直到 Close
+func (k *KeyedPool[T]) Watch(r registry.Registry, name string, timeout time.Duration) error {
+	events, err := r.Subscribe(name)
+	if err != nil {
+		return err
+	}
+
+	// 首次拉取也在监听的 goroutine 中执行，和之后的拉取串行，
+	// 并且拉取失败时也不会丢下订阅的 channel，导致注册中心阻塞在发送上
+	synced := make(chan error, 1)
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		synced <- k.sync(r, name, timeout)
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				// 拉取失败时等待下一次事件
+				_ = k.sync(r, name, timeout)
+			case <-k.ctx.Done():
+				return
+			}
+		}
+	}()
+	return <-synced
+}
+
+// Close 关闭所有子连接池，语义同 Pool.Close
+func (k *KeyedPool[T]) Close(ctx context.Context) error {
+	k.lock.Lock()
+	k.closed = true
+	pools := k.pools
+	k.pools = make(map[string]*keyedEntry[T])
+	k.lock.Unlock()
+
+	var err error
+	for _, e := range pools {
+		if cerr := e.pool.Close(ctx); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	k.cancel()
+	k.wg.Wait()
+	return err
+}
+
+func (k *KeyedPool[T]) isClosed() bool {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.closed
+}
+
+// entry 找到地址对应的子连接池，不存在时创建并补充初始连接。
+// 初始连接需要占用总数的名额，名额被借出的连接占满时会一直等待，所以受 ctx 的限制
+func (k *KeyedPool[T]) entry(ctx context.Context, addr string) (*keyedEntry[T], error) {
+	k.lock.Lock()
+	e, ok := k.pools[addr]
+	closed := k.closed
+	k.lock.Unlock()
+	if closed {
+		return nil, PoolClosedErr
+	}
+	if ok {
+		return e, nil
+	}
+
+	cfg := k.cfg.Pool
+	cfg.Factory = func(ctx context.Context) (T, error) {
+		return k.create(ctx, addr)
+	}
+	cfg.Close = k.closeConn
+	// New 创建初始连接时不受 ctx 的限制，改为创建之后再补充
+	cfg.InitCount = 0
+	p, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	k.lock.Lock()
+	if k.closed {
+		k.lock.Unlock()
+		_ = p.Close(context.Background())
+		return nil, PoolClosedErr
+	}
+	if e, ok = k.pools[addr]; ok {
+		// 并发创建了同一个地址的子连接池
+		k.lock.Unlock()
+		_ = p.Close(context.Background())
+		return e, nil
+	}
+	e = &keyedEntry[T]{pool: p}
+	e.lastUsed.Store(time.Now().UnixNano())
+	k.pools[addr] = e
+	k.lock.Unlock()
+
+	// 补充时可能需要销毁其他地址的空闲连接腾出名额，不能持有锁。
+	// 失败时等到 Get 再创建
+	p.fillIdle(ctx, min(k.cfg.Pool.InitCount, k.cfg.Pool.MaxIdleCount))
+	return e, nil
+}
+
+// create 占用总数的名额之后再创建连接
+func (k *KeyedPool[T]) create(ctx context.Context, addr string) (T, error) {
+	var zero T
+	if k.sem != nil {
+		if err := k.acquire(ctx); err != nil {
+			return zero, err
+		}
+	}
+	c, err := k.cfg.Factory(ctx, addr)
+	if err != nil && k.sem != nil {
+		<-k.sem
+	}
+	return c, err
+}
+
+func (k *KeyedPool[T]) acquire(ctx context.Context) error {
+	for {
+		select {
+		case k.sem <- struct{}{}:
+			return nil
+		default:
+		}
+		// 销毁一个其他地址的空闲连接腾出名额
+		if k.closeOneIdle() {
+			continue
+		}
+		select {
+		case k.sem <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (k *KeyedPool[T]) closeOneIdle() bool {
+	k.lock.Lock()
+	pools := make([]*Pool[T], 0, len(k.pools))
+	for _, e := range k.pools {
+		pools = append(pools, e.pool)
+	}
+	k.lock.Unlock()
+	for _, p := range pools {
+		if p.closeOneIdle() {
+			return true
+		}
+	}
+	return false
+}
+
+// closeConn 销毁连接并归还总数的名额
+func (k *KeyedPool[T]) closeConn(c T) error {
+	err := k.close(c)
+	if k.sem != nil {
+		<-k.sem
+	}
+	return err
+}
+
+// retire 关闭被移除的子连接池，在后台等待借出的连接放回，KeyedPool 关闭时不再等待
+func (k *KeyedPool[T]) retire(p *Pool[T]) {
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		_ = p.Close(k.ctx)
+	}()
+}
+
+func (k *KeyedPool[T]) evictLoop() {
+	defer k.wg.Done()
+	ticker := time.NewTicker(k.cfg.IdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			k.evictIdle()
+		case <-k.ctx.Done():
+			return
+		}
+	}
+}
+
+// evictIdle 销毁没有借出的连接、也没有等待的请求，并且超过 IdleTimeout 没有被使用的子连接池
+func (k *KeyedPool[T]) evictIdle() {
+	deadline := time.Now().Add(-k.cfg.IdleTimeout).UnixNano()
+	var retired []*Pool[T]
+	k.lock.Lock()
+	for addr, e := range k.pools {
+		if e.lastUsed.Load() > deadline {
+			continue
+		}
+		if s := e.pool.Stats(); s.InUse > 0 || s.Waiters > 0 {
+			continue
+		}
+		delete(k.pools, addr)
+		retired = append(retired, e.pool)
+	}
+	k.lock.Unlock()
+	for _, p := range retired {
+		k.retire(p)
+	}
+}
+
+// sync 拉取服务的全部实例，创建新上线的地址的子连接池，移除下线的
+func (k *KeyedPool[T]) sync(r registry.Registry, name string, timeout time.Duration) error {
+	// 创建子连接池的初始连接同样受 timeout 的限制，KeyedPool 关闭时立刻取消
+	ctx, cancel := context.WithTimeout(k.ctx, timeout)
+	defer cancel()
+	services, err := r.ListServices(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	addrs := make(map[string]struct{}, len(services))
+	for _, si := range services {
+		addrs[si.Addr] = struct{}{}
+	}
+	k.lock.Lock()
+	if k.closed {
+		k.lock.Unlock()
+		return PoolClosedErr
+	}
+	old := k.watched[name]
+	k.watched[name] = addrs
+	k.lock.Unlock()
+
+	for addr := range addrs {
+		if _, ok := old[addr]; !ok {
+			// 初始连接创建失败时，等到第一次 Get 再创建
+			_, _ = k.entry(ctx, addr)
+		}
+	}
+	for addr := range old {
+		if _, ok := addrs[addr]; !ok {
+			k.Remove(addr)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package connection_pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xiaoyeshiyu/micro-tools/registry"
+)
+
+type addrConn struct {
+	mockConn
+	addr string
+}
+
+func newKeyedPool(t *testing.T, cfg KeyedConfig[*addrConn]) *KeyedPool[*addrConn] {
+	cfg.Factory = func(ctx context.Context, addr string) (*addrConn, error) {
+		return &addrConn{addr: addr}, nil
+	}
+	k, err := NewKeyedPool(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = k.Close(ctx)
+	})
+	return k
+}
+
+func TestKeyedPool_Get(t *testing.T) {
+	k := newKeyedPool(t, KeyedConfig[*addrConn]{
+		Pool: Config[*addrConn]{MaxCount: 1, MaxIdleCount: 1},
+	})
+	a, err := k.Get(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "a", a.Conn.addr)
+
+	// 每个地址的连接数上限互不影响
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = k.Get(ctx, "a")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	b, err := k.Get(context.Background(), "b")
+	require.NoError(t, err)
+	assert.Equal(t, "b", b.Conn.addr)
+	assert.Equal(t, []string{"a", "b"}, k.Keys())
+
+	require.NoError(t, a.Put(context.Background()))
+	assert.Equal(t, ConnReleasedErr, a.Put(context.Background()))
+	assert.Equal(t, 1, k.Stats()["a"].Idle)
+	require.NoError(t, b.Discard(context.Background()))
+	assert.True(t, b.Conn.closed.Load())
+}
+
+func TestKeyedPool_MaxTotal(t *testing.T) {
+	k := newKeyedPool(t, KeyedConfig[*addrConn]{
+		Pool:     Config[*addrConn]{MaxCount: 2, MaxIdleCount: 2},
+		MaxTotal: 2,
+	})
+	a1, err := k.Get(context.Background(), "a")
+	require.NoError(t, err)
+	a2, err := k.Get(context.Background(), "a")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = k.Get(ctx, "b")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// 放回之后的空闲连接被销毁，为其他地址腾出名额
+	require.NoError(t, a1.Put(context.Background()))
+	b, err := k.Get(context.Background(), "b")
+	require.NoError(t, err)
+	assert.True(t, a1.Conn.closed.Load())
+
+	// 借出的连接放回之后，等待的请求拿到名额
+	got := make(chan *KeyedConn[*addrConn], 1)
+	go func() {
+		c, err := k.Get(context.Background(), "c")
+		assert.NoError(t, err)
+		got <- c
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, a2.Discard(context.Background()))
+	c := <-got
+	assert.Equal(t, "c", c.Conn.addr)
+	require.NoError(t, b.Put(context.Background()))
+	require.NoError(t, c.Put(context.Background()))
+}
+
+func TestKeyedPool_IdleTimeout(t *testing.T) {
+	k := newKeyedPool(t, KeyedConfig[*addrConn]{
+		Pool:        Config[*addrConn]{MaxCount: 1, MaxIdleCount: 1},
+		IdleTimeout: 20 * time.Millisecond,
+	})
+	a, err := k.Get(context.Background(), "a")
+	require.NoError(t, err)
+	b, err := k.Get(context.Background(), "b")
+	require.NoError(t, err)
+	require.NoError(t, a.Put(context.Background()))
+
+	// 有借出连接的子连接池不会被销毁
+	assert.Eventually(t, func() bool {
+		keys := k.Keys()
+		return len(keys) == 1 && keys[0] == "b"
+	}, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, a.Conn.closed.Load, time.Second, time.Millisecond)
+	require.NoError(t, b.Put(context.Background()))
+}
+
+type fakeRegistry struct {
+	lock      sync.Mutex
+	instances []registry.ServiceInstance
+	events    chan registry.Event
+	listErr   error
+}
+
+func (r *fakeRegistry) Register(ctx context.Context, si registry.ServiceInstance) error {
+	r.lock.Lock()
+	r.instances = append(r.instances, si)
+	r.lock.Unlock()
+	r.events <- registry.Event{}
+	return nil
+}
+
+func (r *fakeRegistry) UnRegister(ctx context.Context, si registry.ServiceInstance) error {
+	r.lock.Lock()
+	for i, s := range r.instances {
+		if s.Addr == si.Addr {
+			r.instances = append(r.instances[:i], r.instances[i+1:]...)
+			break
+		}
+	}
+	r.lock.Unlock()
+	r.events <- registry.Event{}
+	return nil
+}
+
+func (r *fakeRegistry) ListServices(ctx context.Context, name string) ([]registry.ServiceInstance, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.listErr != nil {
+		return nil, r.listErr
+	}
+	return append([]registry.ServiceInstance(nil), r.instances...), nil
+}
+
+func (r *fakeRegistry) Subscribe(name string) (<-chan registry.Event, error) {
+	return r.events, nil
+}
+
+func (r *fakeRegistry) Close() error {
+	return nil
+}
+
+func TestKeyedPool_MaxTotalInitCount(t *testing.T) {
+	r := &fakeRegistry{events: make(chan registry.Event)}
+	k := newKeyedPool(t, KeyedConfig[*addrConn]{
+		Pool:     Config[*addrConn]{MaxCount: 1, MaxIdleCount: 1, InitCount: 1},
+		MaxTotal: 1,
+	})
+	require.NoError(t, k.Watch(r, "user", 50*time.Millisecond))
+	a, err := k.Get(context.Background(), "a")
+	require.NoError(t, err)
+
+	// 名额被借出的连接占满，创建子连接池时补充初始连接受 ctx 的限制，不会一直阻塞
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = k.Get(ctx, "b")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Watch 补充初始连接同样受 timeout 的限制，之后的事件依然会被处理
+	require.NoError(t, r.Register(context.Background(), registry.ServiceInstance{Name: "user", Addr: "c"}))
+	require.NoError(t, r.Register(context.Background(), registry.ServiceInstance{Name: "user", Addr: "d"}))
+	assert.Eventually(t, func() bool {
+		return len(k.Keys()) == 4
+	}, time.Second, 5*time.Millisecond)
+
+	// 放回之后的空闲连接被销毁，为其他地址腾出名额
+	require.NoError(t, a.Put(context.Background()))
+	b, err := k.Get(context.Background(), "b")
+	require.NoError(t, err)
+	require.NoError(t, b.Put(context.Background()))
+}
+
+func TestKeyedPool_WatchSyncFailed(t *testing.T) {
+	listErr := errors.New("registry unavailable")
+	r := &fakeRegistry{
+		instances: []registry.ServiceInstance{{Name: "user", Addr: "a"}},
+		events:    make(chan registry.Event),
+		listErr:   listErr,
+	}
+	k := newKeyedPool(t, KeyedConfig[*addrConn]{
+		Pool: Config[*addrConn]{MaxCount: 1, MaxIdleCount: 1},
+	})
+	assert.Equal(t, listErr, k.Watch(r, "user", time.Second))
+	assert.Empty(t, k.Keys())
+
+	// 首次拉取失败之后仍然在消费事件，注册中心不会阻塞在发送上
+	r.lock.Lock()
+	r.listErr = nil
+	r.lock.Unlock()
+	registered := make(chan error, 1)
+	go func() {
+		registered <- r.Register(context.Background(), registry.ServiceInstance{Name: "user", Addr: "b"})
+	}()
+	select {
+	case err := <-registered:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("registry blocked on sending event")
+	}
+	assert.Eventually(t, func() bool {
+		keys := k.Keys()
+		return len(keys) == 2 && keys[0] == "a" && keys[1] == "b"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestKeyedPool_Watch(t *testing.T) {
+	r := &fakeRegistry{
+		instances: []registry.ServiceInstance{{Name: "user", Addr: "a"}, {Name: "user", Addr: "b"}},
+		events:    make(chan registry.Event),
+	}
+	k := newKeyedPool(t, KeyedConfig[*addrConn]{
+		Pool: Config[*addrConn]{MaxCount: 1, MaxIdleCount: 1, InitCount: 1},
+	})
+	require.NoError(t, k.Watch(r, "user", time.Second))
+	assert.Equal(t, []string{"a", "b"}, k.Keys())
+	assert.Equal(t, 1, k.Stats()["a"].Idle)
+
+	a, err := k.Get(context.Background(), "a")
+	require.NoError(t, err)
+	require.NoError(t, r.UnRegister(context.Background(), registry.ServiceInstance{Name: "user", Addr: "a"}))
+	require.NoError(t, r.Register(context.Background(), registry.ServiceInstance{Name: "user", Addr: "c"}))
+	assert.Eventually(t, func() bool {
+		keys := k.Keys()
+		return len(keys) == 2 && keys[0] == "b" && keys[1] == "c"
+	}, time.Second, 5*time.Millisecond)
+
+	// 下线的实例借出的连接放回时销毁
+	assert.False(t, a.Conn.closed.Load())
+	require.NoError(t, a.Put(context.Background()))
+	assert.True(t, a.Conn.closed.Load())
+}
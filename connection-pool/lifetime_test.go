@@ -0,0 +1,77 @@
+package connection_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_MaxLifetime(t *testing.T) {
+	t.Run("get", func(t *testing.T) {
+		p := newMockPool(t, Config[*mockConn]{
+			MaxCount: 1, MaxIdleCount: 1, InitCount: 1, MaxLifetime: 20 * time.Millisecond,
+		})
+		old := (<-p.idleConns)
+		p.idleConns <- old
+		time.Sleep(30 * time.Millisecond)
+
+		c, err := p.Get(context.Background())
+		require.NoError(t, err)
+		assert.NotSame(t, old.c, c)
+		assert.True(t, old.c.closed.Load())
+	})
+
+	t.Run("put", func(t *testing.T) {
+		p := newMockPool(t, Config[*mockConn]{
+			MaxCount: 1, MaxIdleCount: 1, MaxLifetime: 20 * time.Millisecond,
+		})
+		c, err := p.Get(context.Background())
+		require.NoError(t, err)
+		got := make(chan *mockConn, 1)
+		go func() {
+			c, err := p.Get(context.Background())
+			assert.NoError(t, err)
+			got <- c
+		}()
+		time.Sleep(30 * time.Millisecond)
+
+		// 一直在使用的连接超过存活时间之后放回时也会被销毁，等待的请求拿到新建的连接
+		require.NoError(t, p.Put(context.Background(), c))
+		assert.True(t, c.closed.Load())
+		assert.NotSame(t, c, <-got)
+	})
+
+	t.Run("evict", func(t *testing.T) {
+		p := newMockPool(t, Config[*mockConn]{
+			MaxCount: 1, MaxIdleCount: 1, InitCount: 1,
+			MaxLifetime: 20 * time.Millisecond, EvictInterval: 5 * time.Millisecond,
+		})
+		defer p.Close(context.Background())
+		old := (<-p.idleConns)
+		p.idleConns <- old
+		assert.Eventually(t, old.c.closed.Load, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		p := newMockPool(t, Config[*mockConn]{
+			MaxCount: 1, MaxLifetime: time.Minute, MaxLifetimeJitter: 10 * time.Second,
+		})
+		for i := 0; i < 100; i++ {
+			lifetime := p.newIdleConn(&mockConn{}).lifetime
+			assert.GreaterOrEqual(t, lifetime, 50*time.Second)
+			assert.LessOrEqual(t, lifetime, time.Minute)
+		}
+	})
+
+	t.Run("not comparable", func(t *testing.T) {
+		_, err := New(Config[[]byte]{
+			MaxCount:    1,
+			Factory:     func(context.Context) ([]byte, error) { return nil, nil },
+			MaxLifetime: time.Minute,
+		})
+		assert.Error(t, err)
+	})
+}
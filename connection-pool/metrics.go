@@ -0,0 +1,92 @@
+package connection_pool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsGetter 任意 T 的 Pool 以及 ConnPool 都实现了这个接口
+type StatsGetter interface {
+	Stats() Stats
+}
+
+// Collector 采集时读取连接池的 Stats，实现了 prometheus.Collector，由使用方自行注册。
+// 同一个进程中有多个连接池时通过 name 区分，name 作为 pool 标签
+type Collector struct {
+	pool StatsGetter
+
+	maxOpen       *prometheus.Desc
+	open          *prometheus.Desc
+	idle          *prometheus.Desc
+	inUse         *prometheus.Desc
+	waiters       *prometheus.Desc
+	waitCount     *prometheus.Desc
+	waitDuration  *prometheus.Desc
+	created       *prometheus.Desc
+	closed        *prometheus.Desc
+	factoryErrors *prometheus.Desc
+	evictions     *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+func NewCollector(namespace, name string, pool StatsGetter) *Collector {
+	labels := prometheus.Labels{"pool": name}
+	desc := func(metric, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "connection_pool", metric), help, variableLabels, labels)
+	}
+	return &Collector{
+		pool: pool,
+
+		maxOpen:       desc("max_open", "Maximum number of open connections."),
+		open:          desc("open", "Number of open connections, both idle and in use."),
+		idle:          desc("idle", "Number of idle connections."),
+		inUse:         desc("in_use", "Number of connections currently borrowed."),
+		waiters:       desc("waiters", "Number of requests currently waiting for a connection."),
+		waitCount:     desc("wait_total", "Total number of requests that had to wait for a connection."),
+		waitDuration:  desc("wait_duration_seconds_total", "Total time spent waiting for a connection."),
+		created:       desc("created_total", "Total number of connections created."),
+		closed:        desc("closed_total", "Total number of connections closed."),
+		factoryErrors: desc("factory_errors_total", "Total number of failed attempts to create a connection."),
+		evictions:     desc("evictions_total", "Total number of connections closed by the pool, partitioned by reason.", "reason"),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.idle
+	ch <- c.inUse
+	ch <- c.waiters
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.created
+	ch <- c.closed
+	ch <- c.factoryErrors
+	ch <- c.evictions
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stats()
+	gauge := func(desc *prometheus.Desc, v int) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(v))
+	}
+	counter := func(desc *prometheus.Desc, v float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v, labelValues...)
+	}
+
+	gauge(c.maxOpen, s.MaxOpen)
+	gauge(c.open, s.Open)
+	gauge(c.idle, s.Idle)
+	gauge(c.inUse, s.InUse)
+	gauge(c.waiters, s.Waiters)
+	counter(c.waitCount, float64(s.WaitCount))
+	counter(c.waitDuration, s.WaitDuration.Seconds())
+	counter(c.created, float64(s.Created))
+	counter(c.closed, float64(s.Closed))
+	counter(c.factoryErrors, float64(s.FactoryErrors))
+	counter(c.evictions, float64(s.MaxIdleClosed), "max_idle")
+	counter(c.evictions, float64(s.MaxIdleTimeClosed), "max_idle_time")
+	counter(c.evictions, float64(s.MaxLifetimeClosed), "max_lifetime")
+	counter(c.evictions, float64(s.InvalidClosed), "invalid")
+}
@@ -0,0 +1,37 @@
+package connection_pool
+
+import (
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// pingTimeout PingConn 读取时等待的时间，在这段时间内没有数据也没有 EOF 就认为连接可用
+const pingTimeout = time.Millisecond
+
+var UnexpectedReadErr = errors.New("connection_pool: unexpected read on idle connection")
+
+// PingConn 检查空闲的 net.Conn 是否可用：对端关闭时读取会立刻返回 EOF，
+// 空闲连接上读到数据说明协议状态已经错乱，同样认为不可用
+func PingConn(c net.Conn) error {
+	if err := c.SetReadDeadline(time.Now().Add(pingTimeout)); err != nil {
+		return err
+	}
+	var buf [1]byte
+	n, err := c.Read(buf[:])
+	// 恢复为不超时
+	if derr := c.SetReadDeadline(time.Time{}); derr != nil {
+		return derr
+	}
+	switch {
+	case n > 0:
+		return UnexpectedReadErr
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return nil
+	case err == nil:
+		return UnexpectedReadErr
+	default:
+		return err
+	}
+}
@@ -0,0 +1,66 @@
+package connection_pool
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPingConn(t *testing.T) {
+	testCases := []struct {
+		name    string
+		peer    func(peer net.Conn)
+		wantErr error
+	}{
+		{
+			name: "alive",
+			peer: func(peer net.Conn) {},
+		},
+		{
+			name: "closed by peer",
+			peer: func(peer net.Conn) {
+				_ = peer.Close()
+			},
+			wantErr: io.EOF,
+		},
+		{
+			name: "unexpected data",
+			peer: func(peer net.Conn) {
+				go func() {
+					_, _ = peer.Write([]byte("x"))
+				}()
+			},
+			wantErr: UnexpectedReadErr,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, peer := tcpPipe(t)
+			defer c.Close()
+			defer peer.Close()
+			tc.peer(peer)
+			assert.ErrorIs(t, PingConn(c), tc.wantErr)
+		})
+	}
+}
+
+// tcpPipe 返回一对通过本地回环连接的 TCP 连接
+func tcpPipe(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := l.Accept()
+		accepted <- c
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	peer := <-accepted
+	require.NotNil(t, peer)
+	return c, peer
+}
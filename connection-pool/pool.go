@@ -2,75 +2,218 @@ package connection_pool
 
 import (
 	"context"
-	"net"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-type idleConn struct {
-	c              net.Conn
-	lastActiveTime time.Time // 上次活跃时间，例如被创建、被使用，记录上次 TCP 的使用时间
+var PoolClosedErr = errors.New("connection_pool: pool is closed")
+
+type idleConn[T any] struct {
+	c              T
+	lastActiveTime time.Time     // 上次活跃时间，例如被创建、被使用，记录上次 TCP 的使用时间
+	createdTime    time.Time     // 创建时间
+	lifetime       time.Duration // 加上抖动之后的最大存活时间，为 0 时不限制
+}
+
+// connRequest 等待中的请求。conn 的缓冲为 1，交付时不会阻塞，等待者超时离开之后也不会泄漏 goroutine。
+// 交付 nil 表示有连接被销毁，空出的名额已经为等待者占用，由它自己新建连接
+type connRequest[T any] struct {
+	conn chan *idleConn[T]
 }
 
-type connRequest struct {
-	conn chan net.Conn
+// Config 连接池的配置，T 可以是 net.Conn、gRPC 客户端连接、数据库会话等任意资源
+type Config[T any] struct {
+	MaxCount     int           // 最大连接数
+	MaxIdleCount int           // 最大空闲连接数
+	MaxIdleTime  time.Duration // 最大空闲连接时间，为 0 时不限制
+	InitCount    int           // 初始连接数
+
+	Factory  func(ctx context.Context) (T, error) // 创建连接，必须。ctx 来自 Get，后台补充连接时在连接池关闭后取消
+	Close    func(T) error                        // 销毁连接，为空时如果 T 实现了 io.Closer 则调用 Close
+	Validate func(T) error                        // 校验连接是否可用，例如 ping，为空时不校验
+
+	DialTimeout    time.Duration // 每次调用 Factory 的超时时间，为 0 时只受 ctx 的限制
+	DialRetries    int           // 创建失败之后的重试次数，为 0 时不重试
+	DialBackoff    time.Duration // 第一次重试前等待的时间，之后每次翻倍，默认 10ms
+	DialMaxBackoff time.Duration // 重试等待时间的上限，默认 1s
+
+	// 连续创建失败（包括重试）这么多次之后熔断，熔断期间不再调用 Factory，直接返回 CircuitOpenErr，为 0 时不熔断。
+	// 后端故障时避免所有请求同时重连
+	BreakerThreshold int
+	// 熔断之后等待多久再放行一个请求试探，默认 1s
+	BreakerCooldown time.Duration
+
+	// 空闲超过这个时间的连接在取出时才会校验，为 0 时每次取出都校验。
+	// 刚用过的连接大概率是可用的，避免每次都付出校验的开销
+	TestOnBorrowInterval time.Duration
+	// 放回时是否校验，不可用的连接直接销毁
+	TestOnReturn bool
+
+	// 后台清理的间隔，为 0 时不启动后台清理，过期的连接只在 Get 时销毁
+	EvictInterval time.Duration
+	// 后台清理时保持的最少空闲连接数，不足时通过 Factory 补充，不会超过 MaxIdleCount
+	MinIdle int
+
+	// 连接从创建开始的最大存活时间，即使一直在使用，超过之后也会在 Get、Put 和后台清理时销毁，为 0 时不限制。
+	// 负载均衡后面的服务扩容之后，只有连接被重建流量才会重新分布
+	MaxLifetime time.Duration
+	// 每个连接的存活时间在 [MaxLifetime-MaxLifetimeJitter, MaxLifetime] 之间随机，避免同时创建的连接同时过期
+	MaxLifetimeJitter time.Duration
 }
 
-type Pool struct {
-	idleConns    chan *idleConn           // 空闲连接 chan
-	requestQueue []connRequest            // 阻塞的请求
-	maxCount     int                      // 最大连接数
-	currCount    int32                    // 当前连接数
-	maxIdleCount int                      // 最大空闲连接数
-	maxIdleTime  time.Duration            // 最大空闲连接时间
-	initCount    int                      // 初始连接数
-	factory      func() (net.Conn, error) // 工厂模式
+type Pool[T any] struct {
+	idleConns    chan *idleConn[T]                    // 空闲连接 chan
+	requestQueue []*connRequest[T]                    // 阻塞的请求
+	maxCount     int                                  // 最大连接数
+	currCount    int32                                // 当前连接数
+	maxIdleCount int                                  // 最大空闲连接数
+	maxIdleTime  time.Duration                        // 最大空闲连接时间
+	initCount    int                                  // 初始连接数
+	factory      func(ctx context.Context) (T, error) // 工厂模式
+	close        func(T) error                        // 销毁连接
+	validate     func(T) error                        // 校验连接
 	lock         sync.Mutex
+
+	testOnBorrowInterval time.Duration // 空闲超过这个时间才在取出时校验
+	testOnReturn         bool          // 放回时校验
+
+	minIdle   int           // 最少空闲连接数
+	stop      chan struct{} // 连接池关闭，通知后台清理退出、唤醒等待的请求
+	evictDone chan struct{} // 后台清理已经退出
+	closeOnce sync.Once
+
+	closed    bool          // 已经关闭，由 lock 保护
+	inUse     int32         // 借出的连接数
+	drained   chan struct{} // 关闭之后借出的连接都已经放回
+	drainOnce sync.Once
+
+	maxLifetime       time.Duration        // 最大存活时间
+	maxLifetimeJitter time.Duration        // 存活时间的抖动
+	borrowed          map[any]*idleConn[T] // 借出的连接，用于放回时找到创建时间，以及拒绝重复放回
+	trackBorrowed     bool                 // T 可比较时才能作为 map 的 key 记录借出的连接
+
+	stats poolStats
+
+	dialTimeout    time.Duration // 每次创建的超时时间
+	dialRetries    int           // 创建失败的重试次数
+	dialBackoff    time.Duration // 重试等待的初始时间
+	dialMaxBackoff time.Duration // 重试等待时间的上限
+	breaker        *breaker
+
+	beforeLock func() // Get 没有取到空闲连接、加锁之前调用，便于测试
 }
 
-func NewPool(
-	maxCount int,
-	maxIdleCount int,
-	maxIdleTime time.Duration,
-	initCount int,
-	factory func() (net.Conn, error),
-) (*Pool, error) {
+func New[T any](cfg Config[T]) (*Pool[T], error) {
+	if cfg.Factory == nil {
+		return nil, errors.New("connection_pool: factory is nil")
+	}
+
 	// 当 maxIdleCount > maxCount
 	//	 也就是说最大空闲连接数大于最大连接数，此时应该 增加 最大连接数，还是减少最大空闲连接数？
 	//   应该选择后者，强制限制以最大连接数为主，维护系统安全
-	if maxIdleCount > maxCount {
-		maxIdleCount = maxCount
+	if cfg.MaxIdleCount > cfg.MaxCount {
+		cfg.MaxIdleCount = cfg.MaxCount
 	}
 
 	// 同样的，初始连接个数也不应该大于最大空闲连接数
-	if initCount > maxIdleCount {
-		initCount = maxIdleCount
+	if cfg.InitCount > cfg.MaxIdleCount {
+		cfg.InitCount = cfg.MaxIdleCount
+	}
+
+	if cfg.MinIdle > cfg.MaxIdleCount {
+		cfg.MinIdle = cfg.MaxIdleCount
+	}
+
+	if cfg.MaxLifetimeJitter > cfg.MaxLifetime {
+		cfg.MaxLifetimeJitter = cfg.MaxLifetime
+	}
+
+	// 借出的连接需要作为 map 的 key 找回创建时间
+	if cfg.MaxLifetime > 0 && !reflect.TypeFor[T]().Comparable() {
+		return nil, errors.New("connection_pool: MaxLifetime requires a comparable connection type")
+	}
+
+	if cfg.Close == nil {
+		cfg.Close = closeIfCloser[T]
+	}
+
+	if cfg.DialBackoff <= 0 {
+		cfg.DialBackoff = defaultDialBackoff
+	}
+	if cfg.DialMaxBackoff <= 0 {
+		cfg.DialMaxBackoff = defaultDialMaxBackoff
 	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = defaultBreakerCooldown
+	}
+
+	p := &Pool[T]{
+		idleConns:    make(chan *idleConn[T], cfg.MaxIdleCount),
+		maxCount:     cfg.MaxCount,
+		maxIdleCount: cfg.MaxIdleCount,
+		maxIdleTime:  cfg.MaxIdleTime,
+		factory:      cfg.Factory,
+		close:        cfg.Close,
+		validate:     cfg.Validate,
+
+		testOnBorrowInterval: cfg.TestOnBorrowInterval,
+		testOnReturn:         cfg.TestOnReturn,
 
-	// 初始化连接
-	idleConns := make(chan *idleConn, maxIdleCount)
-	for i := 0; i < initCount; i++ {
-		c, err := factory()
+		minIdle:   cfg.MinIdle,
+		stop:      make(chan struct{}),
+		evictDone: make(chan struct{}),
+		drained:   make(chan struct{}),
+
+		maxLifetime:       cfg.MaxLifetime,
+		maxLifetimeJitter: cfg.MaxLifetimeJitter,
+		borrowed:          make(map[any]*idleConn[T]),
+		trackBorrowed:     reflect.TypeFor[T]().Comparable(),
+
+		dialTimeout:    cfg.DialTimeout,
+		dialRetries:    cfg.DialRetries,
+		dialBackoff:    cfg.DialBackoff,
+		dialMaxBackoff: cfg.DialMaxBackoff,
+		breaker:        &breaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown},
+	}
+
+	// 初始化连接，初始连接同样计入当前连接数
+	for i := 0; i < cfg.InitCount; i++ {
+		c, err := p.create(context.Background())
 		if err != nil {
+			p.closeIdle()
 			return nil, err
 		}
-		idleConns <- &idleConn{c: c, lastActiveTime: time.Now()}
+		atomic.AddInt32(&p.currCount, 1)
+		p.idleConns <- p.newIdleConn(c)
+	}
+
+	if cfg.EvictInterval > 0 {
+		go p.evictLoop(cfg.EvictInterval)
+	} else {
+		close(p.evictDone)
 	}
+	return p, nil
+}
 
-	return &Pool{
-		idleConns:    idleConns,
-		maxCount:     maxCount,
-		maxIdleCount: maxIdleCount,
-		maxIdleTime:  maxIdleTime,
-		factory:      factory,
-	}, nil
+func closeIfCloser[T any](c T) error {
+	if closer, ok := any(c).(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
 }
 
-func (p *Pool) Get(ctx context.Context) (net.Conn, error) {
+func (p *Pool[T]) Get(ctx context.Context) (T, error) {
+	var zero T
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return zero, ctx.Err()
+	case <-p.stop:
+		return zero, PoolClosedErr
 	default:
 	}
 
@@ -78,74 +221,404 @@ func (p *Pool) Get(ctx context.Context) (net.Conn, error) {
 		select {
 		case c := <-p.idleConns:
 			// 获取到连接
-			if c.lastActiveTime.Add(p.maxIdleTime).Before(time.Now()) {
-				// 连接已经过期
-				_ = c.c.Close()
-				atomic.AddInt32(&p.currCount, -1)
-				continue
+			if p.usable(c) {
+				p.borrow(c)
+				return c.c, nil
 			}
-			return c.c, nil
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return zero, ctx.Err()
 		default:
 			// 没有空闲连接，也没有超时
+			if p.beforeLock != nil {
+				p.beforeLock()
+			}
 			p.lock.Lock()
-			// 如果当前连接数大于等于最大连接数
-			if int(p.currCount) >= p.maxCount {
-				// 进入等待队列
-				req := connRequest{conn: make(chan net.Conn)}
-				p.requestQueue = append(p.requestQueue, req)
+			// 加锁之前连接池可能刚好被关闭，此时 Close 可能已经返回，不能再借出连接
+			if p.closed {
 				p.lock.Unlock()
-
-				select {
-				case c := <-req.conn:
-					return c, nil
-				case <-ctx.Done():
-					// 如果超时，还需要销毁自己的 chan，选择保险的方法是放回去
-					go func() {
-						c := <-req.conn
-						_ = p.Put(ctx, c)
-					}()
-					return nil, ctx.Err()
+				return zero, PoolClosedErr
+			}
+			// 放回空闲连接时持有锁，加锁之前可能刚好有连接放回，此时不应该进入等待队列
+			select {
+			case c := <-p.idleConns:
+				p.lock.Unlock()
+				if p.usable(c) {
+					p.borrow(c)
+					return c.c, nil
 				}
+				continue
+			default:
 			}
-
-			p.lock.Unlock()
-
-			conn, err := p.factory()
-			if err != nil {
-				return nil, err
+			// 如果当前连接数大于等于最大连接数，进入等待队列
+			if int(atomic.LoadInt32(&p.currCount)) >= p.maxCount {
+				req := &connRequest[T]{conn: make(chan *idleConn[T], 1)}
+				p.requestQueue = append(p.requestQueue, req)
+				p.lock.Unlock()
+				return p.wait(ctx, req)
 			}
+			// 先占用名额再新建，保证并发新建时也不会超过最大连接数
 			atomic.AddInt32(&p.currCount, 1)
+			p.lock.Unlock()
+			return p.newConn(ctx)
+		}
+	}
+}
 
-			return conn, nil
+// usable 检查取出的空闲连接，过期或者不可用时销毁
+func (p *Pool[T]) usable(c *idleConn[T]) bool {
+	if reason, ok := p.expired(c); ok {
+		// 连接已经过期
+		_ = p.destroyConn(c.c, reason)
+		return false
+	}
+	if p.shouldTestOnBorrow(c) && p.validate(c.c) != nil {
+		// 连接不可用，销毁之后继续取下一个，没有空闲连接时会新建
+		_ = p.destroyConn(c.c, closeReasonInvalid)
+		return false
+	}
+	return true
+}
+
+// wait 等待 Put 交付连接，或者空出名额之后自己新建
+func (p *Pool[T]) wait(ctx context.Context, req *connRequest[T]) (T, error) {
+	var zero T
+	start := time.Now()
+	atomic.AddInt64(&p.stats.waitCount, 1)
+	defer p.waited(start)
+
+	select {
+	case c := <-req.conn:
+		if c == nil {
+			// 有连接被销毁，名额已经为自己占用
+			return p.newConn(ctx)
 		}
+		p.borrow(c)
+		return c.c, nil
+	case <-ctx.Done():
+		p.cancelWait(req)
+		return zero, ctx.Err()
+	case <-p.stop:
+		p.cancelWait(req)
+		return zero, PoolClosedErr
 	}
 }
 
-func (p *Pool) Put(ctx context.Context, conn net.Conn) error {
+// cancelWait 超时或者连接池关闭时离开等待队列。
+// 交付总是在持有锁的时候从队列中取出请求并写入缓冲，所以不在队列中时缓冲里一定已经有交付的连接或者名额
+func (p *Pool[T]) cancelWait(req *connRequest[T]) {
 	p.lock.Lock()
+	for i, r := range p.requestQueue {
+		if r == req {
+			p.requestQueue = append(p.requestQueue[:i], p.requestQueue[i+1:]...)
+			p.lock.Unlock()
+			return
+		}
+	}
+	p.lock.Unlock()
+
+	if c := <-req.conn; c != nil {
+		// 转交给下一个等待的请求或者放回空闲连接
+		_ = p.put(c)
+		return
+	}
+	// 名额转交给下一个等待的请求
+	atomic.AddInt32(&p.currCount, -1)
+	p.freeSlot()
+}
+
+// newConn 在已经占用名额的情况下新建连接，失败时归还名额
+func (p *Pool[T]) newConn(ctx context.Context) (T, error) {
+	conn, err := p.create(ctx)
+	if err != nil {
+		atomic.AddInt32(&p.currCount, -1)
+		p.freeSlot()
+		return conn, err
+	}
+	p.borrow(p.newIdleConn(conn))
+	return conn, nil
+}
+
+// Put 放回借出的连接。T 可比较时，重复放回或者放回不是从这个连接池借出的连接返回 ConnReleasedErr
+func (p *Pool[T]) Put(ctx context.Context, conn T) error {
+	c, ok := p.release(conn)
+	if !ok {
+		return ConnReleasedErr
+	}
+	// 连接池已经关闭、连接超过最大存活时间或者不可用，直接销毁
+	switch {
+	case p.isClosed():
+		return p.destroy(conn, closeReasonPoolClosed)
+	case p.exceedLifetime(c):
+		return p.destroy(conn, closeReasonMaxLifetime)
+	case p.testOnReturn && p.validate != nil && p.validate(conn) != nil:
+		return p.destroy(conn, closeReasonInvalid)
+	}
+	c.lastActiveTime = time.Now()
+	return p.put(c)
+}
+
+// Discard 销毁借出的连接而不是放回，例如连接上出现了 I/O 错误。
+// 如果有等待的请求，空出的名额交给它新建连接
+func (p *Pool[T]) Discard(ctx context.Context, conn T) error {
+	if _, ok := p.release(conn); !ok {
+		return ConnReleasedErr
+	}
+	return p.destroy(conn, closeReasonInvalid)
+}
+
+// borrow 记录借出的连接
+func (p *Pool[T]) borrow(c *idleConn[T]) {
+	atomic.AddInt32(&p.inUse, 1)
+	if p.trackBorrowed {
+		p.lock.Lock()
+		p.borrowed[c.c] = c
+		p.lock.Unlock()
+	}
+}
+
+// release 找回借出的连接的创建时间。已经放回或者不是从这个连接池借出的连接返回 false，不修改计数。
+// T 不可比较时无法记录，总是当作借出的连接
+func (p *Pool[T]) release(conn T) (*idleConn[T], bool) {
+	if !p.trackBorrowed {
+		atomic.AddInt32(&p.inUse, -1)
+		return &idleConn[T]{c: conn}, true
+	}
+	p.lock.Lock()
+	c, ok := p.borrowed[conn]
+	delete(p.borrowed, conn)
+	p.lock.Unlock()
+	if ok {
+		atomic.AddInt32(&p.inUse, -1)
+	}
+	return c, ok
+}
+
+// destroy 销毁借出的连接，如果有等待的请求，空出的名额交给它
+func (p *Pool[T]) destroy(conn T, reason closeReason) error {
+	err := p.destroyConn(conn, reason)
+	if p.isClosed() {
+		p.checkDrained()
+		return err
+	}
+	p.freeSlot()
+	return err
+}
+
+// put 将可用的连接交给等待的请求或者放入空闲连接
+func (p *Pool[T]) put(conn *idleConn[T]) error {
+	p.lock.Lock()
+
+	if p.closed {
+		p.lock.Unlock()
+		err := p.destroyConn(conn.c, closeReasonPoolClosed)
+		p.checkDrained()
+		return err
+	}
 
 	if len(p.requestQueue) != 0 {
 		// 拿取队首的，容易超时，但是遵循先进先出
 		// 拿取队尾，基本上不超时，但是不易理解
 		req := p.requestQueue[0]
 		p.requestQueue = p.requestQueue[1:]
-		p.lock.Unlock()
-
+		// 缓冲为 1，不会阻塞
 		req.conn <- conn
+		p.lock.Unlock()
 		return nil
 	}
-	p.lock.Lock()
 
 	// 没有阻塞的请求
 	select {
 	// 将连接放入连接池中
-	case p.idleConns <- &idleConn{c: conn, lastActiveTime: time.Now()}:
+	case p.idleConns <- conn:
+		p.lock.Unlock()
+		return nil
 	default:
-		atomic.AddInt32(&p.currCount, -1)
-		return conn.Close()
+		p.lock.Unlock()
+		return p.destroyConn(conn.c, closeReasonMaxIdle)
 	}
+}
 
-	return nil
+// freeSlot 有连接被销毁之后，如果有等待的请求并且没有超过最大连接数，为它占用名额并唤醒它新建连接
+func (p *Pool[T]) freeSlot() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed || len(p.requestQueue) == 0 || int(atomic.LoadInt32(&p.currCount)) >= p.maxCount {
+		return
+	}
+	req := p.requestQueue[0]
+	p.requestQueue = p.requestQueue[1:]
+	atomic.AddInt32(&p.currCount, 1)
+	req.conn <- nil
+}
+
+func (p *Pool[T]) shouldTestOnBorrow(c *idleConn[T]) bool {
+	if p.validate == nil {
+		return false
+	}
+	return time.Since(c.lastActiveTime) >= p.testOnBorrowInterval
+}
+
+func (p *Pool[T]) isClosed() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.closed
+}
+
+// newIdleConn 记录新建连接的创建时间，并随机出它的存活时间
+func (p *Pool[T]) newIdleConn(c T) *idleConn[T] {
+	now := time.Now()
+	lifetime := p.maxLifetime
+	if p.maxLifetimeJitter > 0 {
+		lifetime -= time.Duration(rand.Int63n(int64(p.maxLifetimeJitter) + 1))
+	}
+	return &idleConn[T]{c: c, lastActiveTime: now, createdTime: now, lifetime: lifetime}
+}
+
+// expired 空闲时间超过 maxIdleTime，或者超过最大存活时间，同时返回过期的原因
+func (p *Pool[T]) expired(c *idleConn[T]) (closeReason, bool) {
+	if p.exceedLifetime(c) {
+		return closeReasonMaxLifetime, true
+	}
+	if p.maxIdleTime > 0 && c.lastActiveTime.Add(p.maxIdleTime).Before(time.Now()) {
+		return closeReasonMaxIdleTime, true
+	}
+	return 0, false
+}
+
+func (p *Pool[T]) exceedLifetime(c *idleConn[T]) bool {
+	return c.lifetime > 0 && time.Since(c.createdTime) >= c.lifetime
+}
+
+// Close 关闭连接池：之后的 Get 返回 PoolClosedErr，等待中的请求被唤醒，空闲连接被销毁。
+// 借出的连接在 Put 时销毁，Close 会等到它们都被放回，或者 ctx 超时。
+// 不想等待借出的连接时传入一个已经取消的 ctx 即可，空闲连接依然会被销毁
+func (p *Pool[T]) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		// 等待的请求由 stop 唤醒之后自己离开等待队列
+		p.lock.Lock()
+		p.closed = true
+		p.lock.Unlock()
+		close(p.stop)
+	})
+
+	// 等待后台清理退出，避免清理之后又补充空闲连接。
+	// stop 关闭之后后台清理会立刻退出，正在补充的连接也会被取消，所以这里不受 ctx 的限制
+	<-p.evictDone
+	p.closeIdle()
+	p.checkDrained()
+
+	// 没有借出的连接时即使 ctx 已经取消也算成功
+	select {
+	case <-p.drained:
+		return nil
+	default:
+	}
+	select {
+	case <-p.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeIdle 销毁所有空闲连接
+func (p *Pool[T]) closeIdle() {
+	for {
+		select {
+		case c := <-p.idleConns:
+			_ = p.destroyConn(c.c, closeReasonPoolClosed)
+		default:
+			return
+		}
+	}
+}
+
+// checkDrained 关闭之后借出的连接都已经放回时通知 Close
+func (p *Pool[T]) checkDrained() {
+	if atomic.LoadInt32(&p.inUse) > 0 {
+		return
+	}
+	p.drainOnce.Do(func() {
+		close(p.drained)
+	})
+}
+
+func (p *Pool[T]) evictLoop(interval time.Duration) {
+	defer close(p.evictDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// 连接池关闭时取消正在进行的补充
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		select {
+		case <-ticker.C:
+			p.evict()
+			p.fillIdle(ctx, p.minIdle)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// evict 销毁过期的空闲连接。只检查当前已有的空闲连接，没有过期的按照原来的顺序放回
+func (p *Pool[T]) evict() {
+	for i, n := 0, len(p.idleConns); i < n; i++ {
+		var c *idleConn[T]
+		select {
+		case c = <-p.idleConns:
+		default:
+			// 被 Get 取走了
+			return
+		}
+		if reason, ok := p.expired(c); ok {
+			_ = p.destroyConn(c.c, reason)
+			continue
+		}
+		// 通过 put 持有锁放回，有等待的请求时直接交给它，空闲连接已满时销毁
+		_ = p.put(c)
+	}
+}
+
+// fillIdle 空闲连接不足 n 个时补充，不会超过最大连接数。
+// 补充的连接同样通过 put 放回，有等待的请求时优先交给它
+func (p *Pool[T]) fillIdle(ctx context.Context, n int) {
+	for {
+		p.lock.Lock()
+		if p.closed || len(p.idleConns) >= n || int(atomic.LoadInt32(&p.currCount)) >= p.maxCount {
+			p.lock.Unlock()
+			return
+		}
+		atomic.AddInt32(&p.currCount, 1)
+		p.lock.Unlock()
+
+		c, err := p.create(ctx)
+		if err != nil {
+			// 下一轮再试
+			atomic.AddInt32(&p.currCount, -1)
+			p.freeSlot()
+			return
+		}
+		if err = p.put(p.newIdleConn(c)); err != nil {
+			return
+		}
+	}
+}
+
+// closeOneIdle 销毁一个空闲连接，KeyedPool 达到连接总数上限时为其他地址腾出名额
+func (p *Pool[T]) closeOneIdle() bool {
+	select {
+	case c := <-p.idleConns:
+		_ = p.destroyConn(c.c, closeReasonMaxIdle)
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,213 @@
+package connection_pool
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_GetPut(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{MaxCount: 2, MaxIdleCount: 1})
+	c1, err := p.Get(context.Background())
+	require.NoError(t, err)
+	c2, err := p.Get(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, p.Put(context.Background(), c1))
+	// 空闲连接已满，直接销毁
+	require.NoError(t, p.Put(context.Background(), c2))
+	assert.True(t, c2.closed.Load())
+
+	c, err := p.Get(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, c1, c)
+	s := p.Stats()
+	assert.Equal(t, 1, s.Open)
+	assert.Equal(t, int64(1), s.MaxIdleClosed)
+}
+
+func TestPool_InitCount(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{MaxCount: 2, MaxIdleCount: 2, InitCount: 2})
+	assert.Equal(t, 2, p.Stats().Open)
+	for i := 0; i < 2; i++ {
+		_, err := p.Get(context.Background())
+		require.NoError(t, err)
+	}
+
+	// 初始连接计入当前连接数，不会再新建
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := p.Get(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, int64(2), p.Stats().Created)
+}
+
+func TestPool_WaitTimeout(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{MaxCount: 1, MaxIdleCount: 1})
+	c, err := p.Get(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Get(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	// 超时的请求已经离开等待队列，放回的连接进入空闲连接而不是交给它
+	assert.Equal(t, 0, p.Stats().Waiters)
+	require.NoError(t, p.Put(context.Background(), c))
+	assert.Equal(t, 1, p.Stats().Idle)
+
+	got, err := p.Get(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, c, got)
+}
+
+func TestPool_FactoryError(t *testing.T) {
+	var fail atomic.Bool
+	p := newMockPool(t, Config[*mockConn]{
+		MaxCount: 1,
+		Factory: func(context.Context) (*mockConn, error) {
+			if fail.Load() {
+				return nil, errors.New("dial failed")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	c, err := p.Get(context.Background())
+	require.NoError(t, err)
+	waitErr := make(chan error, 1)
+	go func() {
+		_, err := p.Get(context.Background())
+		waitErr <- err
+	}()
+	require.Eventually(t, func() bool {
+		return p.Stats().Waiters == 1
+	}, time.Second, time.Millisecond)
+
+	// 连接被销毁之后等待的请求自己新建连接，失败时返回错误而不是一直等待
+	fail.Store(true)
+	require.NoError(t, p.Discard(context.Background(), c))
+	select {
+	case err := <-waitErr:
+		assert.EqualError(t, err, "dial failed")
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken up")
+	}
+	assert.Equal(t, 0, p.Stats().Open)
+
+	fail.Store(false)
+	_, err = p.Get(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestPool_Concurrent(t *testing.T) {
+	const maxCount = 5
+	var live, maxLive atomic.Int32
+	var started atomic.Bool
+	p, err := New(Config[*mockConn]{
+		MaxCount:     maxCount,
+		MaxIdleCount: 3,
+		MaxIdleTime:  time.Minute,
+		InitCount:    2,
+		Factory: func(context.Context) (*mockConn, error) {
+			if started.Load() && rand.Intn(10) == 0 {
+				return nil, errors.New("dial failed")
+			}
+			n := live.Add(1)
+			for {
+				m := maxLive.Load()
+				if n <= m || maxLive.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			return &mockConn{}, nil
+		},
+		Close: func(c *mockConn) error {
+			live.Add(-1)
+			return c.Close()
+		},
+	})
+	require.NoError(t, err)
+	started.Store(true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rand.Intn(2000))*time.Microsecond)
+				c, err := p.Get(ctx)
+				cancel()
+				if err != nil {
+					continue
+				}
+				time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+				if rand.Intn(5) == 0 {
+					_ = p.Discard(context.Background(), c)
+				} else {
+					_ = p.Put(context.Background(), c)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s := p.Stats()
+	assert.LessOrEqual(t, maxLive.Load(), int32(maxCount))
+	assert.Equal(t, 0, s.InUse)
+	assert.Equal(t, 0, s.Waiters)
+	assert.Equal(t, s.Idle, s.Open)
+	assert.Equal(t, int(live.Load()), s.Open)
+	assert.Equal(t, s.Created-s.Closed, int64(s.Open))
+
+	require.NoError(t, p.Close(context.Background()))
+	assert.Equal(t, int32(0), live.Load())
+}
+
+// Get 发现没有空闲连接之后、加锁之前，Put 刚好放回了连接，Get 不应该进入等待队列
+func TestPool_GetPutRace(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{MaxCount: 1, MaxIdleCount: 1})
+	c, err := p.Get(context.Background())
+	require.NoError(t, err)
+	var once sync.Once
+	p.beforeLock = func() {
+		once.Do(func() {
+			require.NoError(t, p.Put(context.Background(), c))
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	got, err := p.Get(ctx)
+	require.NoError(t, err)
+	assert.Same(t, c, got)
+}
+
+func TestPool_PutTwice(t *testing.T) {
+	p := newMockPool(t, Config[*mockConn]{MaxCount: 2, MaxIdleCount: 2})
+
+	c, err := p.Get(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, p.Put(context.Background(), c))
+	// 重复放回不会让同一个连接进入两次空闲队列
+	assert.Equal(t, ConnReleasedErr, p.Put(context.Background(), c))
+	assert.Equal(t, ConnReleasedErr, p.Discard(context.Background(), c))
+	// 不是从这个连接池借出的连接
+	assert.Equal(t, ConnReleasedErr, p.Put(context.Background(), &mockConn{}))
+
+	c1, err := p.Get(context.Background())
+	require.NoError(t, err)
+	c2, err := p.Get(context.Background())
+	require.NoError(t, err)
+	assert.NotSame(t, c1, c2)
+	s := p.Stats()
+	assert.Equal(t, 2, s.InUse)
+	assert.Equal(t, 0, s.Idle)
+}
@@ -0,0 +1,91 @@
+package connection_pool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats 连接池的统计信息，参考 database/sql.DBStats
+type Stats struct {
+	MaxOpen int // 最大连接数
+
+	Open    int // 当前连接数，包括空闲和借出的
+	Idle    int // 空闲连接数
+	InUse   int // 借出的连接数
+	Waiters int // 正在等待连接的请求数
+
+	WaitCount    int64         // 累计等待的次数
+	WaitDuration time.Duration // 累计等待的时间
+
+	Created       int64 // 累计创建的连接数
+	Closed        int64 // 累计销毁的连接数
+	FactoryErrors int64 // 累计创建失败的次数
+
+	// 以下是按照原因统计的销毁连接数，连接池关闭时销毁的连接不计入其中
+	MaxIdleClosed     int64 // 空闲连接已满
+	MaxIdleTimeClosed int64 // 空闲超过 MaxIdleTime
+	MaxLifetimeClosed int64 // 超过 MaxLifetime
+	InvalidClosed     int64 // 校验失败或者被 Discard
+}
+
+// closeReason 销毁连接的原因
+type closeReason int
+
+const (
+	closeReasonPoolClosed closeReason = iota
+	closeReasonMaxIdle
+	closeReasonMaxIdleTime
+	closeReasonMaxLifetime
+	closeReasonInvalid
+
+	closeReasonCount
+)
+
+type poolStats struct {
+	waitCount     int64
+	waitDuration  int64
+	created       int64
+	closed        int64
+	factoryErrors int64
+	closedBy      [closeReasonCount]int64
+}
+
+func (p *Pool[T]) Stats() Stats {
+	p.lock.Lock()
+	waiters := len(p.requestQueue)
+	p.lock.Unlock()
+
+	return Stats{
+		MaxOpen: p.maxCount,
+
+		Open:    int(atomic.LoadInt32(&p.currCount)),
+		Idle:    len(p.idleConns),
+		InUse:   int(atomic.LoadInt32(&p.inUse)),
+		Waiters: waiters,
+
+		WaitCount:    atomic.LoadInt64(&p.stats.waitCount),
+		WaitDuration: time.Duration(atomic.LoadInt64(&p.stats.waitDuration)),
+
+		Created:       atomic.LoadInt64(&p.stats.created),
+		Closed:        atomic.LoadInt64(&p.stats.closed),
+		FactoryErrors: atomic.LoadInt64(&p.stats.factoryErrors),
+
+		MaxIdleClosed:     atomic.LoadInt64(&p.stats.closedBy[closeReasonMaxIdle]),
+		MaxIdleTimeClosed: atomic.LoadInt64(&p.stats.closedBy[closeReasonMaxIdleTime]),
+		MaxLifetimeClosed: atomic.LoadInt64(&p.stats.closedBy[closeReasonMaxLifetime]),
+		InvalidClosed:     atomic.LoadInt64(&p.stats.closedBy[closeReasonInvalid]),
+	}
+}
+
+// destroyConn 销毁连接并计数
+func (p *Pool[T]) destroyConn(c T, reason closeReason) error {
+	atomic.AddInt32(&p.currCount, -1)
+	atomic.AddInt64(&p.stats.closed, 1)
+	atomic.AddInt64(&p.stats.closedBy[reason], 1)
+	return p.close(c)
+}
+
+// waited 记录一次等待
+func (p *Pool[T]) waited(start time.Time) {
+	atomic.AddInt64(&p.stats.waitDuration, int64(time.Since(start)))
+}
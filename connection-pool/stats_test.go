@@ -0,0 +1,106 @@
+package connection_pool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_Stats(t *testing.T) {
+	var fail atomic.Bool
+	p := newMockPool(t, Config[*mockConn]{
+		MaxCount:     1,
+		MaxIdleCount: 1,
+		Factory: func(context.Context) (*mockConn, error) {
+			if fail.Load() {
+				return nil, errors.New("dial failed")
+			}
+			return &mockConn{}, nil
+		},
+	})
+
+	fail.Store(true)
+	_, err := p.Get(context.Background())
+	require.Error(t, err)
+	fail.Store(false)
+
+	c1, err := p.Get(context.Background())
+	require.NoError(t, err)
+	got := make(chan *mockConn, 1)
+	go func() {
+		c, err := p.Get(context.Background())
+		assert.NoError(t, err)
+		got <- c
+	}()
+	assert.Eventually(t, func() bool {
+		return p.Stats().Waiters == 1
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	// 销毁之后新建一个交给等待的请求
+	require.NoError(t, p.Discard(context.Background(), c1))
+	<-got
+
+	s := p.Stats()
+	assert.Equal(t, 1, s.MaxOpen)
+	assert.Equal(t, 1, s.Open)
+	assert.Equal(t, 0, s.Idle)
+	assert.Equal(t, 1, s.InUse)
+	assert.Equal(t, 0, s.Waiters)
+	assert.Equal(t, int64(1), s.WaitCount)
+	assert.GreaterOrEqual(t, s.WaitDuration, 10*time.Millisecond)
+	assert.Equal(t, int64(2), s.Created)
+	assert.Equal(t, int64(1), s.Closed)
+	assert.Equal(t, int64(1), s.FactoryErrors)
+	assert.Equal(t, int64(1), s.InvalidClosed)
+}
+
+type fixedStats Stats
+
+func (s fixedStats) Stats() Stats {
+	return Stats(s)
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("test", "redis", fixedStats{
+		MaxOpen:           10,
+		Open:              4,
+		Idle:              1,
+		InUse:             3,
+		Waiters:           2,
+		WaitCount:         5,
+		WaitDuration:      1500 * time.Millisecond,
+		Created:           7,
+		Closed:            3,
+		FactoryErrors:     1,
+		MaxIdleTimeClosed: 2,
+		InvalidClosed:     1,
+	})
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(c))
+
+	err := testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP test_connection_pool_in_use Number of connections currently borrowed.
+# TYPE test_connection_pool_in_use gauge
+test_connection_pool_in_use{pool="redis"} 3
+# HELP test_connection_pool_wait_duration_seconds_total Total time spent waiting for a connection.
+# TYPE test_connection_pool_wait_duration_seconds_total counter
+test_connection_pool_wait_duration_seconds_total{pool="redis"} 1.5
+# HELP test_connection_pool_evictions_total Total number of connections closed by the pool, partitioned by reason.
+# TYPE test_connection_pool_evictions_total counter
+test_connection_pool_evictions_total{pool="redis",reason="invalid"} 1
+test_connection_pool_evictions_total{pool="redis",reason="max_idle"} 0
+test_connection_pool_evictions_total{pool="redis",reason="max_idle_time"} 2
+test_connection_pool_evictions_total{pool="redis",reason="max_lifetime"} 0
+`), "test_connection_pool_in_use", "test_connection_pool_wait_duration_seconds_total", "test_connection_pool_evictions_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 14, testutil.CollectAndCount(c))
+}
@@ -0,0 +1,83 @@
+package rate_limiter
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// NewAdminHandler 限流器的管理接口，可以通过 http.StripPrefix 挂载到任意路径下：
+//
+//	GET  /keys?prefix=     列出当前有计数的 key
+//	GET  /usage?key=       查询 key 的使用情况
+//	POST /reset?key=       清空 key 的计数
+//	POST /consume?key=&n=  手动消耗 n 个请求数
+//
+// key 和 prefix 为空时使用 WithKey 指定的 key，两者都为空时返回 400。
+// 接口本身没有鉴权，需要使用方自行保护
+func NewAdminHandler(r *RateLimiter) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, req *http.Request) {
+		keys, err := r.Keys(req.Context(), req.URL.Query().Get("prefix"))
+		if err != nil {
+			writeAdminError(w, adminErrorStatus(err), err)
+			return
+		}
+		if keys == nil {
+			keys = []string{}
+		}
+		writeJSON(w, http.StatusOK, map[string][]string{"keys": keys})
+	})
+	mux.HandleFunc("GET /usage", func(w http.ResponseWriter, req *http.Request) {
+		usage, err := r.Peek(req.Context(), req.URL.Query().Get("key"))
+		if err != nil {
+			writeAdminError(w, adminErrorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, usage)
+	})
+	mux.HandleFunc("POST /reset", func(w http.ResponseWriter, req *http.Request) {
+		if err := r.Reset(req.Context(), req.URL.Query().Get("key")); err != nil {
+			writeAdminError(w, adminErrorStatus(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /consume", func(w http.ResponseWriter, req *http.Request) {
+		n, err := strconv.ParseUint(req.URL.Query().Get("n"), 10, 64)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, err)
+			return
+		}
+		usage, err := r.Consume(req.Context(), req.URL.Query().Get("key"), n)
+		switch {
+		case err == nil:
+			writeJSON(w, http.StatusOK, usage)
+		case isRejected(err):
+			writeJSON(w, http.StatusTooManyRequests, usage)
+		default:
+			writeAdminError(w, adminErrorStatus(err), err)
+		}
+	})
+	return mux
+}
+
+// adminErrorStatus 缺少 key、消耗的请求数过多属于请求错误，其余都是 redis 的错误
+func adminErrorStatus(err error) int {
+	if errors.Is(err, EmptyKeyErr) || errors.Is(err, CostTooLargeErr) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,132 @@
+package rate_limiter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAdminHandler(t *testing.T) {
+	env := newTestEnv(t)
+	_ = env.mr.Set("other", "1")
+	r := env.newRateLimiter(t, WithWindow(time.Minute, 5))
+
+	for _, key := range []string{"user:1", "user:1", "user:2"} {
+		require.NoError(t, r.Limit(context.Background(), key, nil))
+	}
+
+	handler := NewAdminHandler(r)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	w := do(http.MethodGet, "/keys?prefix=user:")
+	require.Equal(t, http.StatusOK, w.Code)
+	var keys map[string][]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	assert.ElementsMatch(t, []string{"user:1", "user:2"}, keys["keys"])
+
+	// 查询不消耗
+	for i := 0; i < 2; i++ {
+		w = do(http.MethodGet, "/usage?key=user:1")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"key":"user:1","count":2,"remaining":3,"limit":5}`, w.Body.String())
+	}
+
+	w = do(http.MethodPost, "/consume?key=user:1&n=3")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"key":"user:1","count":5,"remaining":0,"limit":5}`, w.Body.String())
+	assert.Equal(t, LimitedErr, r.Limit(context.Background(), "user:1", nil))
+
+	// 剩余不足时不消耗
+	w = do(http.MethodPost, "/consume?key=user:2&n=5")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"key":"user:2","count":1,"remaining":4,"limit":5}`, w.Body.String())
+
+	w = do(http.MethodPost, "/consume?key=user:2&n=abc")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	// 滑动窗口日志单次消耗的请求数有上限，避免长时间阻塞 redis
+	w = do(http.MethodPost, "/consume?key=user:2&n=1001")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodPost, "/reset?key=user:1")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, r.Limit(context.Background(), "user:1", nil))
+}
+
+func TestEscapePattern(t *testing.T) {
+	assert.Equal(t, `user\*\?\[1\]\\`, escapePattern(`user*?[1]\`))
+}
+
+func TestNewAdminHandler_EmptyKey(t *testing.T) {
+	env := newTestEnv(t)
+	_ = env.mr.Set("other", "1")
+
+	testCases := []struct {
+		name   string
+		opts   []Option
+		method string
+		target string
+		want   int
+	}{
+		// 没有 WithKey 时必须指定 key，不能操作 redis 中的 "" 或者扫描所有 key
+		{name: "keys", method: http.MethodGet, target: "/keys", want: http.StatusBadRequest},
+		{name: "usage", method: http.MethodGet, target: "/usage", want: http.StatusBadRequest},
+		{name: "reset", method: http.MethodPost, target: "/reset", want: http.StatusBadRequest},
+		{name: "consume", method: http.MethodPost, target: "/consume?n=1", want: http.StatusBadRequest},
+		// 有 WithKey 时使用默认的 key
+		{name: "default key", opts: []Option{WithKey("api")}, method: http.MethodGet, target: "/usage", want: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := env.newRateLimiter(t, append([]Option{WithWindow(time.Minute, 5)}, tc.opts...)...)
+			w := httptest.NewRecorder()
+			NewAdminHandler(r).ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+	assert.Equal(t, []string{"other"}, env.mr.Keys())
+}
+
+func TestRateLimiter_Keys(t *testing.T) {
+	env := newTestEnv(t)
+	_ = env.mr.Set("other", "1")
+
+	r := env.newRateLimiter(t, WithWindow(time.Minute, 5))
+	_, err := r.Keys(context.Background(), "")
+	assert.ErrorIs(t, err, EmptyKeyErr)
+	_, err = r.Peek(context.Background(), "")
+	assert.ErrorIs(t, err, EmptyKeyErr)
+	assert.ErrorIs(t, r.Reset(context.Background(), ""), EmptyKeyErr)
+
+	// prefix 为空时只列出 WithKey 指定的 key
+	r = env.newRateLimiter(t, WithKey("api"), WithWindow(time.Minute, 5))
+	require.NoError(t, r.Limit(context.Background(), "", nil))
+	keys, err := r.Keys(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api"}, keys)
+}
+
+func TestRateLimiter_ConsumeCost(t *testing.T) {
+	env := newTestEnv(t)
+	r := env.newRateLimiter(t, WithWindow(time.Minute, 1<<20))
+	_, err := r.Consume(context.Background(), "user:1", maxSlidingLogCost+1)
+	assert.Equal(t, CostTooLargeErr, err)
+	usage, err := r.Consume(context.Background(), "user:1", maxSlidingLogCost)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(maxSlidingLogCost), usage.Count)
+
+	// 计数类的算法只修改计数，不受限制
+	r = env.newRateLimiter(t, WithWindow(time.Minute, 1<<20), WithAlgorithm(FixedWindow))
+	usage, err = r.Consume(context.Background(), "user:2", 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1<<20), usage.Count)
+}
@@ -0,0 +1,81 @@
+package rate_limiter
+
+import (
+	_ "embed"
+	"strconv"
+	"time"
+)
+
+//go:embed lua/sliding_window_counter.lua
+var luaRateLimiterWindowCounter string
+
+//go:embed lua/fixed_window.lua
+var luaRateLimiterFixedWindow string
+
+type Algorithm int
+
+const (
+	// SlidingWindowLog 滑动窗口日志，记录窗口内每一个请求的时间，精确但是内存占用和 rate 成正比
+	SlidingWindowLog Algorithm = iota
+	// SlidingWindowCounter 滑动窗口计数，只保存当前和上一个固定窗口的计数，按照重叠比例估算滑动窗口内的请求数。
+	// 内存占用 O(1)，但是假设上一个窗口内的请求均匀分布，请求集中在上一个窗口末尾时会多放行，集中在开头时会少放行
+	SlidingWindowCounter
+	// FixedWindow 固定窗口计数，内存占用 O(1)，但是在窗口边界最多可能放行 2 倍 rate 的请求
+	FixedWindow
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case SlidingWindowLog:
+		return "sliding_window_log"
+	case SlidingWindowCounter:
+		return "sliding_window_counter"
+	case FixedWindow:
+		return "fixed_window"
+	default:
+		return "unknown"
+	}
+}
+
+func (a Algorithm) valid() bool {
+	return a >= SlidingWindowLog && a <= FixedWindow
+}
+
+// script 返回当前算法的 lua 脚本和参数，rate 为当前的阈值，cost 为本次消耗的请求数
+func (r *RateLimiter) script(now time.Time, rate, cost uint64) (string, []any) {
+	if r.quota != 0 {
+		return r.quotaScript(now, rate, cost)
+	}
+
+	switch r.algorithm {
+	case SlidingWindowCounter:
+		// 窗口按照 Unix 时间对齐
+		current := now.UnixNano() / int64(r.duration)
+		start := time.Unix(0, current*int64(r.duration))
+		weight := 1 - float64(now.Sub(start))/float64(r.duration)
+		// 上一个窗口的计数在当前窗口结束前都需要
+		ttl := start.Add(2 * r.duration).Sub(now)
+		return luaRateLimiterWindowCounter, []any{
+			strconv.FormatInt(current, 10), strconv.FormatInt(current-1, 10), rate, weight, ttlMillis(ttl), cost,
+		}
+	case FixedWindow:
+		current := now.UnixNano() / int64(r.duration)
+		ttl := time.Unix(0, (current+1)*int64(r.duration)).Sub(now)
+		return luaRateLimiterFixedWindow, []any{
+			strconv.FormatInt(current, 10), rate, ttlMillis(ttl), cost,
+		}
+	default:
+		return luaRateLimiterWindows, []any{
+			now.Add(-r.duration).UnixMicro(), rate, now.UnixMicro(), r.duration.Milliseconds(), cost,
+		}
+	}
+}
+
+// ttlMillis 向上取整，PEXPIRE 不接受 0
+func ttlMillis(d time.Duration) int64 {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms < 1 {
+		ms = 1
+	}
+	return int64(ms)
+}
@@ -0,0 +1,90 @@
+package rate_limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Algorithm(t *testing.T) {
+	algorithms := []Algorithm{SlidingWindowLog, SlidingWindowCounter, FixedWindow}
+	for _, algorithm := range algorithms {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			env := newTestEnv(t)
+			r := env.newRateLimiter(t, WithWindow(time.Second, 2), WithAlgorithm(algorithm))
+
+			for i := 0; i < 2; i++ {
+				allow, err := r.allow(context.Background(), "key")
+				require.NoError(t, err)
+				assert.True(t, allow)
+			}
+			allow, err := r.allow(context.Background(), "key")
+			require.NoError(t, err)
+			assert.False(t, allow)
+
+			// 两个窗口之后完全恢复
+			env.advance(2 * time.Second)
+			allow, err = r.allow(context.Background(), "key")
+			require.NoError(t, err)
+			assert.True(t, allow)
+		})
+	}
+}
+
+// 对比窗口边界的突发流量：上一个窗口末尾打满，下一个窗口开头再次突发
+func TestRateLimiter_AlgorithmAccuracy(t *testing.T) {
+	testCases := []struct {
+		algorithm Algorithm
+		// 窗口边界之后 100ms 内放行的请求数
+		wantAllowed int
+	}{
+		// 精确，过去 1s 内已经有 10 个请求
+		{algorithm: SlidingWindowLog, wantAllowed: 0},
+		// 上一个窗口的 10 个请求按照 90% 计入，估算为 9
+		{algorithm: SlidingWindowCounter, wantAllowed: 1},
+		// 新窗口计数清零，边界上 200ms 内放行了 2 倍的请求
+		{algorithm: FixedWindow, wantAllowed: 10},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.algorithm.String(), func(t *testing.T) {
+			env := newTestEnv(t)
+			env.advance(900 * time.Millisecond)
+			r := env.newRateLimiter(t, WithWindow(time.Second, 10), WithAlgorithm(tc.algorithm))
+
+			assert.Equal(t, 10, burst(t, r, 20))
+			env.advance(200 * time.Millisecond)
+			assert.Equal(t, tc.wantAllowed, burst(t, r, 20))
+		})
+	}
+}
+
+// 均匀分布的流量下，滑动窗口计数和滑动窗口日志的结果一致
+func TestRateLimiter_AlgorithmUniform(t *testing.T) {
+	allowed := make(map[Algorithm]int, 2)
+	for _, algorithm := range []Algorithm{SlidingWindowLog, SlidingWindowCounter} {
+		env := newTestEnv(t)
+		r := env.newRateLimiter(t, WithWindow(time.Second, 10), WithAlgorithm(algorithm))
+
+		// 每 50ms 一个请求，是阈值的 2 倍
+		for i := 0; i < 100; i++ {
+			allowed[algorithm] += burst(t, r, 1)
+			env.advance(50 * time.Millisecond)
+		}
+	}
+	assert.InDelta(t, allowed[SlidingWindowLog], allowed[SlidingWindowCounter], 5)
+}
+
+func burst(t *testing.T, r *RateLimiter, n int) int {
+	var allowed int
+	for i := 0; i < n; i++ {
+		allow, err := r.allow(context.Background(), "key")
+		require.NoError(t, err)
+		if allow {
+			allowed++
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,147 @@
+package rate_limiter
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// 上传和下载分别计数
+const (
+	bandwidthRecvSuffix = ":recv"
+	bandwidthSendSuffix = ":send"
+)
+
+// Reader 限制从 r 读取的速度，每秒 rate 个字节
+func (b *TokenBucket) Reader(ctx context.Context, key string, r io.Reader) io.Reader {
+	return &limitedReader{ctx: ctx, key: key, bucket: b, r: r}
+}
+
+// Writer 限制写入 w 的速度，每秒 rate 个字节
+func (b *TokenBucket) Writer(ctx context.Context, key string, w io.Writer) io.Writer {
+	return &limitedWriter{ctx: ctx, key: key, bucket: b, w: w}
+}
+
+// BuildServerMiddleware 限制 gin 请求 body 的读取速度和响应的写入速度，key 用于区分客户端
+func (b *TokenBucket) BuildServerMiddleware(key func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, k := c.Request.Context(), key(c)
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = &limitedReadCloser{
+				Reader: b.Reader(ctx, k+bandwidthRecvSuffix, c.Request.Body),
+				Closer: c.Request.Body,
+			}
+		}
+		c.Writer = &limitedResponseWriter{
+			ResponseWriter: c.Writer,
+			w:              b.Writer(ctx, k+bandwidthSendSuffix, c.Writer),
+		}
+		c.Next()
+	}
+}
+
+// BuildStreamServerInterceptor 限制 gRPC 流收发消息的速度，按照消息序列化之后的大小计算
+func (b *TokenBucket) BuildStreamServerInterceptor(key func(ctx context.Context, info *grpc.StreamServerInfo) string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &limitedServerStream{
+			ServerStream: ss,
+			bucket:       b,
+			key:          key(ss.Context(), info),
+		})
+	}
+}
+
+type limitedReader struct {
+	ctx    context.Context
+	key    string
+	bucket *TokenBucket
+	r      io.Reader
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// 单次读取不超过桶的容量，避免一次等待过久
+	if int64(len(p)) > l.bucket.burst {
+		p = p[:l.bucket.burst]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if werr := l.bucket.WaitN(l.ctx, l.key, int64(n)); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type limitedWriter struct {
+	ctx    context.Context
+	key    string
+	bucket *TokenBucket
+	w      io.Writer
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(int64(len(p)), l.bucket.burst)]
+		if err := l.bucket.WaitN(l.ctx, l.key, int64(len(chunk))); err != nil {
+			return written, err
+		}
+		n, err := l.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+type limitedResponseWriter struct {
+	gin.ResponseWriter
+	w io.Writer
+}
+
+func (l *limitedResponseWriter) Write(p []byte) (int, error) {
+	return l.w.Write(p)
+}
+
+func (l *limitedResponseWriter) WriteString(s string) (int, error) {
+	return l.w.Write([]byte(s))
+}
+
+type limitedServerStream struct {
+	grpc.ServerStream
+	bucket *TokenBucket
+	key    string
+}
+
+func (l *limitedServerStream) SendMsg(m any) error {
+	if err := l.bucket.WaitN(l.Context(), l.key+bandwidthSendSuffix, messageSize(m)); err != nil {
+		return err
+	}
+	return l.ServerStream.SendMsg(m)
+}
+
+// RecvMsg 收到消息之后才知道大小，因此在接收之后等待，下一条消息会被推迟
+func (l *limitedServerStream) RecvMsg(m any) error {
+	if err := l.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return l.bucket.WaitN(l.Context(), l.key+bandwidthRecvSuffix, messageSize(m))
+}
+
+func messageSize(m any) int64 {
+	if msg, ok := m.(proto.Message); ok {
+		return int64(proto.Size(msg))
+	}
+	return 0
+}
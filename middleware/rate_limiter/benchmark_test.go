@@ -0,0 +1,85 @@
+package rate_limiter
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 每个算法分别压测多个 key 和单个热点 key，额外上报 p50、p99 延迟
+func BenchmarkRateLimiter_allow(b *testing.B) {
+	for _, algorithm := range []Algorithm{SlidingWindowLog, SlidingWindowCounter, FixedWindow} {
+		for _, keys := range []int{1024, 1} {
+			name := algorithm.String() + "/many_keys"
+			if keys == 1 {
+				name = algorithm.String() + "/hot_key"
+			}
+			b.Run(name, func(b *testing.B) {
+				r := newTestEnv(b).newRateLimiter(b, WithWindow(time.Second, 100), WithAlgorithm(algorithm))
+				benchmarkParallel(b, keys, func(ctx context.Context, key string) error {
+					_, err := r.allow(ctx, key)
+					return err
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkTokenBucket_reserve(b *testing.B) {
+	for _, keys := range []int{1024, 1} {
+		name := "many_keys"
+		if keys == 1 {
+			name = "hot_key"
+		}
+		b.Run(name, func(b *testing.B) {
+			bucket := newTestEnv(b).newTokenBucket(b, 1000, 100)
+			benchmarkParallel(b, keys, func(ctx context.Context, key string) error {
+				_, err := bucket.reserve(ctx, key, 1)
+				return err
+			})
+		})
+	}
+}
+
+func benchmarkParallel(b *testing.B, keys int, fn func(ctx context.Context, key string) error) {
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = "bench:" + strconv.Itoa(i)
+	}
+
+	var (
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, b.N)
+		seq       atomic.Int64
+	)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		local := make([]time.Duration, 0, 1024)
+		for pb.Next() {
+			key := names[int(seq.Add(1))%keys]
+			start := time.Now()
+			if err := fn(context.Background(), key); err != nil {
+				b.Error(err)
+				return
+			}
+			local = append(local, time.Since(start))
+		}
+		mu.Lock()
+		latencies = append(latencies, local...)
+		mu.Unlock()
+	})
+	b.StopTimer()
+
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	b.ReportMetric(float64(latencies[len(latencies)*50/100].Microseconds()), "p50-us")
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-us")
+}
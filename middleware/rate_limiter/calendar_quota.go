@@ -0,0 +1,41 @@
+package rate_limiter
+
+import (
+	"strconv"
+	"time"
+)
+
+// QuotaPeriod 长周期配额的周期，窗口按照日历对齐
+type QuotaPeriod int
+
+const (
+	// QuotaDaily 每天 0 点重置
+	QuotaDaily QuotaPeriod = iota + 1
+	// QuotaMonthly 每月 1 日 0 点重置
+	QuotaMonthly
+)
+
+func (p QuotaPeriod) valid() bool {
+	return p == QuotaDaily || p == QuotaMonthly
+}
+
+// window 返回 now 所在周期的起止时间，时区以 now 为准
+func (p QuotaPeriod) window(now time.Time) (time.Time, time.Time) {
+	year, month, day := now.Date()
+	switch p {
+	case QuotaMonthly:
+		start := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 0, 1)
+	}
+}
+
+// quotaScript 配额复用固定窗口的脚本，窗口编号为周期开始的 Unix 时间，计数保留到周期结束
+func (r *RateLimiter) quotaScript(now time.Time, rate, cost uint64) (string, []any) {
+	start, end := r.quota.window(now.In(r.location))
+	return luaRateLimiterFixedWindow, []any{
+		strconv.FormatInt(start.Unix(), 10), rate, ttlMillis(end.Sub(now)), cost,
+	}
+}
@@ -0,0 +1,69 @@
+package rate_limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaPeriod_window(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		period    QuotaPeriod
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "daily",
+			period:    QuotaDaily,
+			now:       time.Date(2024, 2, 29, 23, 59, 59, 0, shanghai),
+			wantStart: time.Date(2024, 2, 29, 0, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2024, 3, 1, 0, 0, 0, 0, shanghai),
+		},
+		{
+			name:      "monthly",
+			period:    QuotaMonthly,
+			now:       time.Date(2024, 12, 15, 8, 0, 0, 0, shanghai),
+			wantStart: time.Date(2024, 12, 1, 0, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2025, 1, 1, 0, 0, 0, 0, shanghai),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end := tc.period.window(tc.now)
+			assert.True(t, tc.wantStart.Equal(start))
+			assert.True(t, tc.wantEnd.Equal(end))
+		})
+	}
+}
+
+func TestRateLimiter_Quota(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	env := newTestEnv(t)
+
+	// 上海时间 2024-03-31 23:00，也就是 UTC 15:00
+	env.clock.Set(time.Date(2024, 3, 31, 15, 0, 0, 0, time.UTC))
+	r := env.newRateLimiter(t, WithKey("tenant:1"), WithQuota(QuotaMonthly, 2, shanghai))
+
+	assert.NoError(t, r.Limit(context.Background(), "", nil))
+	env.advance(30 * time.Minute)
+	assert.NoError(t, r.Limit(context.Background(), "", nil))
+	assert.Equal(t, QuotaExceededErr, r.Limit(context.Background(), "", nil))
+	// 计数保留到周期结束
+	assert.Equal(t, 30*time.Minute, env.mr.TTL("tenant:1"))
+
+	// 上海时间进入 4 月，配额重置
+	env.advance(time.Hour)
+	assert.NoError(t, r.Limit(context.Background(), "", nil))
+
+	_, err = New(env.rdb, WithQuota(QuotaMonthly, 2, nil))
+	assert.ErrorIs(t, err, InvalidConfigErr)
+}
@@ -0,0 +1,168 @@
+// loadgen 并发压测限流器，分别针对多个 key 和单个热点 key，输出每秒判断次数和 p50、p99 延迟。
+//
+//	go run ./middleware/rate_limiter/cmd/loadgen -addr localhost:6379 -concurrency 64 -duration 10s
+//
+// 不指定 -addr 时使用进程内的 miniredis
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	rate_limiter "github.com/xiaoyeshiyu/micro-tools/middleware/rate_limiter"
+)
+
+var algorithms = map[string]rate_limiter.Algorithm{
+	"sliding_window_log":     rate_limiter.SlidingWindowLog,
+	"sliding_window_counter": rate_limiter.SlidingWindowCounter,
+	"fixed_window":           rate_limiter.FixedWindow,
+}
+
+func main() {
+	var (
+		addr        = flag.String("addr", "", "redis address, empty to use an in-process miniredis")
+		algorithm   = flag.String("algorithm", "all", "sliding_window_log, sliding_window_counter, fixed_window or all")
+		concurrency = flag.Int("concurrency", 32, "number of concurrent workers")
+		duration    = flag.Duration("duration", 5*time.Second, "duration of each run")
+		keys        = flag.Int("keys", 10000, "number of keys in the many-keys scenario")
+		rate        = flag.Uint64("rate", 100, "requests allowed per window")
+		window      = flag.Duration("window", time.Second, "window size")
+	)
+	flag.Parse()
+
+	backend := "redis"
+	if *addr == "" {
+		mr, err := miniredis.Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer mr.Close()
+		*addr, backend = mr.Addr(), "miniredis"
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: *addr, PoolSize: *concurrency})
+	defer rdb.Close()
+
+	names := make([]string, 0, len(algorithms))
+	if *algorithm == "all" {
+		for name := range algorithms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		if _, ok := algorithms[*algorithm]; !ok {
+			log.Fatalf("unknown algorithm %q", *algorithm)
+		}
+		names = append(names, *algorithm)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "backend\talgorithm\tscenario\tdecisions/s\tallowed\trejected\terrors\tp50\tp99\t")
+	for _, name := range names {
+		for _, keyCount := range []int{*keys, 1} {
+			scenario := "many_keys"
+			if keyCount == 1 {
+				scenario = "hot_key"
+			}
+			limiter, err := rate_limiter.New(rdb,
+				rate_limiter.WithWindow(*window, *rate),
+				rate_limiter.WithAlgorithm(algorithms[name]),
+				rate_limiter.WithName(name))
+			if err != nil {
+				log.Fatal(err)
+			}
+			// 每次压测使用不同的 key，避免互相影响
+			prefix := fmt.Sprintf("loadgen:%s:%s:%d:", name, scenario, time.Now().UnixNano())
+			rep := run(limiter, prefix, keyCount, *concurrency, *duration)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.0f\t%d\t%d\t%d\t%s\t%s\t\n", backend, name, scenario,
+				float64(rep.total())/rep.elapsed.Seconds(), rep.allowed, rep.rejected, rep.errors,
+				rep.percentile(50), rep.percentile(99))
+		}
+	}
+	_ = w.Flush()
+}
+
+type report struct {
+	allowed   int64
+	rejected  int64
+	errors    int64
+	elapsed   time.Duration
+	latencies []time.Duration
+}
+
+func (r *report) total() int64 {
+	return r.allowed + r.rejected + r.errors
+}
+
+func (r *report) percentile(p int) string {
+	if len(r.latencies) == 0 {
+		return "-"
+	}
+	return r.latencies[len(r.latencies)*p/100].Round(time.Microsecond).String()
+}
+
+func run(limiter *rate_limiter.RateLimiter, prefix string, keys, concurrency int, duration time.Duration) *report {
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	var (
+		rep = &report{}
+		mu  sync.Mutex
+		seq atomic.Int64
+		wg  sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var b strings.Builder
+			local := make([]time.Duration, 0, 4096)
+			var allowed, rejected, errors int64
+			for ctx.Err() == nil {
+				b.Reset()
+				b.WriteString(prefix)
+				b.WriteString(strconv.FormatInt(seq.Add(1)%int64(keys), 10))
+
+				begin := time.Now()
+				err := limiter.Limit(ctx, b.String(), nil)
+				local = append(local, time.Since(begin))
+				switch {
+				case err == nil:
+					allowed++
+				case err == rate_limiter.LimitedErr:
+					rejected++
+				case ctx.Err() != nil:
+					// 压测结束时被取消的请求不计入
+					local = local[:len(local)-1]
+				default:
+					errors++
+				}
+			}
+			mu.Lock()
+			rep.allowed += allowed
+			rep.rejected += rejected
+			rep.errors += errors
+			rep.latencies = append(rep.latencies, local...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	rep.elapsed = time.Since(start)
+
+	sort.Slice(rep.latencies, func(i, j int) bool {
+		return rep.latencies[i] < rep.latencies[j]
+	})
+	return rep
+}
@@ -3,36 +3,230 @@ package rate_limiter
 import (
 	"context"
 	_ "embed"
+	"log/slog"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
 )
 
 //go:embed lua/sliding_window.lua
 var luaRateLimiterWindows string
 
+const defaultRuleName = "default"
+
 type RateLimiter struct {
 	client   redis.Cmdable
 	key      string
 	duration time.Duration
 	rate     uint64
+
+	name           string               // 规则名，用于监控
+	metrics        *Metrics             // 监控指标
+	tracerProvider trace.TracerProvider // 链路追踪
+	shadow         bool                 // 影子模式，只记录不限流
+	logger         *slog.Logger
+	allowlist      []Matcher // 白名单
+	denylist       []Matcher // 黑名单
+	trustProxy     bool      // 从代理头中读取客户端 IP
+
+	// 拒绝请求或者出错时的回调
+	ginOnLimited  GinHandler
+	ginOnError    GinHandler
+	grpcOnLimited GrpcHandler
+	grpcOnError   GrpcHandler
+	httpOnLimited HTTPHandler
+	httpOnError   HTTPHandler
+
+	keyFunc  KeyFunc          // 自定义 key
+	failOpen bool             // redis 出错时是否放行
+	now      func() time.Time // 时钟，便于测试
+
+	algorithm Algorithm
+
+	quota    QuotaPeriod    // 长周期配额，0 表示不开启
+	location *time.Location // 配额周期对齐的时区
+
+	// 按照结果计数，为空时在请求进入时计数
+	penalizeHTTPStatus []int
+	penalizeGrpcCodes  []codes.Code
+
+	warmUp *warmUp // 预热，nil 表示不预热
+}
+
+// result 一次限流判断的结果
+type result struct {
+	allowed   bool
+	count     uint64        // 当前窗口内的请求数
+	remaining uint64        // 当前窗口剩余可用的请求数
+	limit     uint64        // 当前的阈值，预热期间小于配置的 rate
+	latency   time.Duration // 执行 lua 脚本的耗时
 }
 
-func NewRateLimiter(client redis.Cmdable, key string, duration time.Duration, rate uint64) *RateLimiter {
-	return &RateLimiter{
-		client:   client,
-		key:      key,
-		duration: duration,
-		rate:     rate,
+// New 创建限流器，backend 为 redis 客户端，至少需要通过 WithWindow 指定窗口和阈值
+func New(backend redis.Cmdable, opts ...Option) (*RateLimiter, error) {
+	r := newRateLimiter(backend, opts)
+	if err := r.validate(); err != nil {
+		return nil, err
 	}
+	return r, nil
+}
+
+// NewRateLimiter 保留原有的构造方式，不做参数校验，推荐使用 New
+func NewRateLimiter(client redis.Cmdable, key string, duration time.Duration, rate uint64, opts ...Option) *RateLimiter {
+	return newRateLimiter(client, append([]Option{WithKey(key), WithWindow(duration, rate)}, opts...))
+}
+
+func newRateLimiter(backend redis.Cmdable, opts []Option) *RateLimiter {
+	r := &RateLimiter{
+		client: backend,
+		logger: slog.Default(),
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.warmUp != nil && r.now != nil {
+		r.warmUp.start = r.now()
+	}
+	if r.name == "" {
+		r.name = r.key
+	}
+	if r.name == "" {
+		r.name = defaultRuleName
+	}
+	return r
+}
+
+func (r *RateLimiter) validate() error {
+	if r.client == nil {
+		return errors.Wrap(InvalidConfigErr, "backend is nil")
+	}
+	if r.quota != 0 {
+		if !r.quota.valid() {
+			return errors.Wrapf(InvalidConfigErr, "unknown quota period %d", r.quota)
+		}
+		if r.location == nil {
+			return errors.Wrap(InvalidConfigErr, "quota location is nil")
+		}
+	} else if r.duration <= 0 {
+		return errors.Wrap(InvalidConfigErr, "duration must be greater than 0")
+	}
+	if r.rate == 0 {
+		return errors.Wrap(InvalidConfigErr, "rate must be greater than 0")
+	}
+	if !r.algorithm.valid() {
+		return errors.Wrapf(InvalidConfigErr, "unknown algorithm %d", r.algorithm)
+	}
+	if err := r.warmUp.validate(); err != nil {
+		return err
+	}
+	if r.logger == nil {
+		return errors.Wrap(InvalidConfigErr, "logger is nil")
+	}
+	if r.now == nil {
+		return errors.Wrap(InvalidConfigErr, "clock is nil")
+	}
+	return nil
 }
 
 func (r *RateLimiter) allow(ctx context.Context, key string) (bool, error) {
+	return r.allowN(ctx, key, 1)
+}
+
+// allowN 消耗 cost 个请求数，cost 为 0 时只检查再来一个请求是否会被放行
+func (r *RateLimiter) allowN(ctx context.Context, key string, cost uint64) (bool, error) {
+	key = r.storeKey(key)
+	keyClass := r.keyClass()
+
+	ctx, span := r.startSpan(ctx, key)
+	res, err := r.eval(ctx, key, cost)
+	r.metrics.observeLatency(r.name, res.latency)
+	if err != nil {
+		endSpan(span, res, decisionErrored, err)
+		r.metrics.incDecision(r.name, keyClass, decisionErrored)
+		return false, err
+	}
+	r.metrics.setUsage(r.name, float64(res.count)/float64(res.limit))
+
+	decision := decisionAllowed
+	if !res.allowed {
+		decision = decisionRejected
+		if r.shadow {
+			// 影子模式下只记录本该被限流的请求，依旧放行
+			decision = decisionShadowRejected
+			r.logger.WarnContext(ctx, "rate limiter would reject request",
+				slog.String("rule", r.name),
+				slog.String("key_hash", hashKey(key)),
+				slog.Uint64("count", res.count))
+		}
+	}
+	endSpan(span, res, decision, nil)
+	r.metrics.incDecision(r.name, keyClass, decision)
+
+	return decision != decisionRejected, nil
+}
+
+// currentRate 当前的阈值，预热期间按照比例缩小
+func (r *RateLimiter) currentRate(now time.Time) uint64 {
+	if r.warmUp == nil {
+		return r.rate
+	}
+	return scale(r.rate, r.warmUp.factor(now))
+}
+
+// storeKey 配置了固定 key 时，所有请求共享同一个窗口
+func (r *RateLimiter) storeKey(key string) string {
 	if r.key != "" {
-		key = r.key
+		return r.key
 	}
+	return key
+}
+
+// limitedErr 拒绝请求时返回的错误
+func (r *RateLimiter) limitedErr() error {
+	if r.quota != 0 {
+		return QuotaExceededErr
+	}
+	return LimitedErr
+}
 
-	return r.client.Eval(ctx, luaRateLimiterWindows, []string{key},
-		time.Now().Add(-r.duration).UnixMicro(), r.rate, time.Now().UnixMicro(), r.duration.Milliseconds()).
-		Bool()
+func (r *RateLimiter) keyClass() string {
+	if r.key != "" {
+		return keyClassFixed
+	}
+	if r.keyFunc != nil {
+		return keyClassCustom
+	}
+	return keyClassRequest
+}
+
+func (r *RateLimiter) eval(ctx context.Context, key string, cost uint64) (result, error) {
+	now, start := r.now(), time.Now()
+	rate := r.currentRate(now)
+	script, args := r.script(now, rate, cost)
+	res, err := r.client.Eval(ctx, script, []string{key}, args...).Int64Slice()
+	latency := time.Since(start)
+	if err != nil {
+		return result{latency: latency}, err
+	}
+
+	count := uint64(res[1])
+	var remaining uint64
+	if count < rate {
+		remaining = rate - count
+	}
+	allowed := res[0] == 1
+	if cost == 0 {
+		allowed = remaining > 0
+	}
+	return result{
+		allowed:   allowed,
+		count:     count,
+		remaining: remaining,
+		limit:     rate,
+		latency:   latency,
+	}, nil
 }
@@ -4,4 +4,13 @@ import "github.com/pkg/errors"
 
 var (
 	LimitedErr = errors.New("request limited")
+	DeniedErr  = errors.New("request denied")
+	// QuotaExceededErr 长周期配额耗尽，区别于短时间的限流 LimitedErr
+	QuotaExceededErr = errors.New("quota exceeded")
+
+	InvalidConfigErr = errors.New("invalid rate limiter config")
+	// EmptyKeyErr 管理接口没有指定 key，也没有通过 WithKey 配置默认的 key
+	EmptyKeyErr = errors.New("empty rate limiter key")
+	// CostTooLargeErr 滑动窗口日志单次 Consume 的请求数超过 maxSlidingLogCost
+	CostTooLargeErr = errors.New("consume cost too large")
 )
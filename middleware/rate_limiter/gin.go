@@ -6,17 +6,56 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// GinHandler 限流或者出错时的回调，需要自行调用 c.Abort 系列方法终止请求
+type GinHandler func(c *gin.Context, err error)
+
+func defaultGinOnLimited(c *gin.Context, err error) {
+	_ = c.AbortWithError(rejectedStatus(err), err)
+}
+
+func defaultGinOnError(c *gin.Context, err error) {
+	_ = c.AbortWithError(http.StatusInternalServerError, err)
+}
+
 func (r *RateLimiter) BuildServerMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// lua 脚本返回 bool 值，判断是否限流
-		allow, err := r.allow(c.Request.Context(), c.Request.RequestURI)
-		if err != nil {
-			c.AbortWithError(http.StatusInternalServerError, err)
+	onLimited, onError := r.ginOnLimited, r.ginOnError
+	if onLimited == nil {
+		onLimited = defaultGinOnLimited
+	}
+	if onError == nil {
+		onError = defaultGinOnError
+	}
+
+	if len(r.penalizeHTTPStatus) > 0 {
+		return func(c *gin.Context) {
+			key, err := r.checkOutcome(c.Request.Context(), c.Request.RequestURI, func() *Request {
+				return newHTTPRequest(c.Request, r.ginClientIP(c))
+			})
+			switch {
+			case err == nil:
+				c.Next()
+				if r.penalizeHTTP(c.Writer.Status()) {
+					r.penalize(c.Request.Context(), key)
+				}
+			case isRejected(err):
+				onLimited(c, err)
+			default:
+				onError(c, err)
+			}
 		}
+	}
 
-		if !allow {
-			c.AbortWithError(http.StatusTooManyRequests, LimitedErr)
+	return func(c *gin.Context) {
+		err := r.limit(c.Request.Context(), c.Request.RequestURI, func() *Request {
+			return newHTTPRequest(c.Request, r.ginClientIP(c))
+		})
+		switch {
+		case err == nil:
+			c.Next()
+		case isRejected(err):
+			onLimited(c, err)
+		default:
+			onError(c, err)
 		}
-		c.Next()
 	}
 }
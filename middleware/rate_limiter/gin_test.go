@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,11 +14,8 @@ type GinResp struct {
 }
 
 func TestRateLimiter_BuildServerMiddleware(t *testing.T) {
-	rdb := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
-
-	middleware := NewRateLimiter(rdb, "rate_limiter", time.Second*5, 2).BuildServerMiddleware()
+	env := newTestEnv(t)
+	middleware := NewRateLimiter(env.rdb, "rate_limiter", time.Second*5, 2, WithClock(env.clock.Now)).BuildServerMiddleware()
 
 	var count uint64
 	router := gin.New()
@@ -35,7 +31,7 @@ func TestRateLimiter_BuildServerMiddleware(t *testing.T) {
 	assert.Equal(t, uint64(1), count)
 
 	// 间隔请求第二次
-	time.Sleep(time.Second * 3)
+	env.advance(time.Second * 3)
 	w = PerformRequest(router, "GET", "/")
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, uint64(2), count)
@@ -46,12 +42,43 @@ func TestRateLimiter_BuildServerMiddleware(t *testing.T) {
 	assert.Equal(t, uint64(2), count)
 
 	// 间隔 5s 避免一个周期
-	time.Sleep(time.Second * 2)
+	env.advance(time.Second * 2)
 	w = PerformRequest(router, "GET", "/")
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, uint64(3), count)
 }
 
+func TestRateLimiter_BuildServerMiddleware_OnError(t *testing.T) {
+	middleware := NewRateLimiter(unreachableRedis(), "rate_limiter", time.Second*5, 2,
+		WithGinOnError(func(c *gin.Context, err error) {
+			// redis 不可用时直接放行
+			c.Header("X-RateLimit-Degraded", "1")
+			c.Next()
+		}),
+		WithDenylist(MatchMethod("/admin/*")),
+		WithGinOnLimited(func(c *gin.Context, err error) {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"code": "throttled", "message": err.Error()})
+		}),
+	).BuildServerMiddleware()
+
+	var count uint64
+	router := gin.New()
+	router.Use(middleware)
+	router.GET("/*path", func(context *gin.Context) {
+		count++
+	})
+
+	w := PerformRequest(router, "GET", "/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Degraded"))
+	assert.Equal(t, uint64(1), count)
+
+	w = PerformRequest(router, "GET", "/admin/users")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"code":"throttled","message":"request denied"}`, w.Body.String())
+	assert.Equal(t, uint64(1), count)
+}
+
 // PerformRequest for testing gin router.
 func PerformRequest(r http.Handler, method, path string) *httptest.ResponseRecorder {
 	req := httptest.NewRequest(method, path, nil)
@@ -4,21 +4,57 @@ import (
 	"context"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 )
 
+// GrpcHandler 限流或者出错时的回调，返回值作为 rpc 的错误返回给客户端，
+// 可以在这里构造带 details 的 status
+type GrpcHandler func(ctx context.Context, info *grpc.UnaryServerInfo, err error) error
+
+func defaultGrpcHandler(_ context.Context, _ *grpc.UnaryServerInfo, err error) error {
+	return err
+}
+
 func (r *RateLimiter) BuildServerInterceptor() grpc.UnaryServerInterceptor {
-	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
-		// lua 脚本返回 bool 值，判断是否限流
-		allow, err := r.allow(ctx, info.FullMethod)
-		if err != nil {
-			return nil, err
-		}
+	onLimited, onError := r.grpcOnLimited, r.grpcOnError
+	if onLimited == nil {
+		onLimited = defaultGrpcHandler
+	}
+	if onError == nil {
+		onError = defaultGrpcHandler
+	}
 
-		if !allow {
-			err = LimitedErr
-			return
+	if len(r.penalizeGrpcCodes) > 0 {
+		return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+			key, err := r.checkOutcome(ctx, info.FullMethod, func() *Request {
+				return newGrpcRequest(ctx, info.FullMethod)
+			})
+			switch {
+			case err == nil:
+				resp, err = handler(ctx, req)
+				if r.penalizeGrpc(status.Code(err)) {
+					r.penalize(ctx, key)
+				}
+				return resp, err
+			case isRejected(err):
+				return nil, onLimited(ctx, info, err)
+			default:
+				return nil, onError(ctx, info, err)
+			}
 		}
+	}
 
-		return handler(ctx, req)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		err = r.limit(ctx, info.FullMethod, func() *Request {
+			return newGrpcRequest(ctx, info.FullMethod)
+		})
+		switch {
+		case err == nil:
+			return handler(ctx, req)
+		case isRejected(err):
+			return nil, onLimited(ctx, info, err)
+		default:
+			return nil, onError(ctx, info, err)
+		}
 	}
 }
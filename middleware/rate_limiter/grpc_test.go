@@ -1,27 +1,30 @@
-//go:build e2e
-
 package rate_limiter
 
 import (
 	"context"
+	"net"
 	"testing"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type GrpcResp struct {
 }
 
 func TestRateLimiter_BuildServerInterceptor(t *testing.T) {
-	rdb := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
-
-	interceptor := NewRateLimiter(rdb, "rate_limiter", time.Second*5, 2).BuildServerInterceptor()
+	env := newTestEnv(t)
+	interceptor := NewRateLimiter(env.rdb, "rate_limiter", time.Second*5, 2, WithClock(env.clock.Now)).BuildServerInterceptor()
 
 	var count uint64
 	handler := func(ctx context.Context, req any) (resp any, err error) {
@@ -36,7 +39,7 @@ func TestRateLimiter_BuildServerInterceptor(t *testing.T) {
 	assert.Equal(t, uint64(1), count)
 
 	// 间隔请求第二次
-	time.Sleep(time.Second * 3)
+	env.advance(time.Second * 3)
 	resp, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
 	require.NoError(t, err)
 	assert.Equal(t, &GrpcResp{}, resp)
@@ -49,9 +52,87 @@ func TestRateLimiter_BuildServerInterceptor(t *testing.T) {
 	assert.Equal(t, uint64(2), count)
 
 	// 休眠 5s 避免一个周期
-	time.Sleep(time.Second * 2)
+	env.advance(time.Second * 2)
 	resp, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
 	require.NoError(t, err)
 	assert.Equal(t, &GrpcResp{}, resp)
 	assert.Equal(t, uint64(3), count)
 }
+
+func TestRateLimiter_BuildServerInterceptor_OnError(t *testing.T) {
+	env := newTestEnv(t)
+	testCases := []struct {
+		name     string
+		limiter  *RateLimiter
+		calls    int
+		wantCode codes.Code
+		wantMsg  string
+	}{
+		{
+			name: "limited",
+			limiter: env.newRateLimiter(t, WithKey("grpc"), WithWindow(time.Second, 1),
+				WithGrpcOnLimited(func(ctx context.Context, info *grpc.UnaryServerInfo, err error) error {
+					st, _ := status.New(codes.ResourceExhausted, err.Error()).WithDetails(&errdetails.RetryInfo{
+						RetryDelay: durationpb.New(time.Second),
+					})
+					return st.Err()
+				})),
+			calls:    2,
+			wantCode: codes.ResourceExhausted,
+			wantMsg:  LimitedErr.Error(),
+		},
+		{
+			name: "redis error",
+			limiter: NewRateLimiter(unreachableRedis(), "grpc", time.Second, 1,
+				WithGrpcOnError(func(ctx context.Context, info *grpc.UnaryServerInfo, err error) error {
+					st, _ := status.New(codes.Unavailable, "rate limiter unavailable").WithDetails(&errdetails.RetryInfo{
+						RetryDelay: durationpb.New(time.Second),
+					})
+					return st.Err()
+				})),
+			calls:    1,
+			wantCode: codes.Unavailable,
+			wantMsg:  "rate limiter unavailable",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newHealthClient(t, tc.limiter.BuildServerInterceptor())
+			var err error
+			for i := 0; i < tc.calls; i++ {
+				_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			}
+
+			// 回调返回的 status 和 details 原样到达客户端
+			st := status.Convert(err)
+			assert.Equal(t, tc.wantCode, st.Code())
+			assert.Equal(t, tc.wantMsg, st.Message())
+			require.Len(t, st.Details(), 1)
+			retry, ok := st.Details()[0].(*errdetails.RetryInfo)
+			require.True(t, ok)
+			assert.Equal(t, time.Second, retry.RetryDelay.AsDuration())
+		})
+	}
+}
+
+// newHealthClient 启动一个带拦截器的 gRPC 服务，通过内存连接访问
+func newHealthClient(t *testing.T, interceptor grpc.UnaryServerInterceptor) healthpb.HealthClient {
+	l := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(interceptor))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() {
+		_ = server.Serve(l)
+	}()
+	t.Cleanup(server.Stop)
+
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cc.Close()
+	})
+	return healthpb.NewHealthClient(cc)
+}
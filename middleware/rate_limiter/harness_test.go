@@ -0,0 +1,82 @@
+package rate_limiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeClock 测试用的时钟，只有调用 Advance 时才会前进
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// testEnv 进程内的 redis 和假时钟，lua 脚本在 miniredis 中执行，不需要真实的 redis，也不需要 sleep
+type testEnv struct {
+	mr    *miniredis.Miniredis
+	rdb   *redis.Client
+	clock *fakeClock
+}
+
+func newTestEnv(t testing.TB) *testEnv {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = rdb.Close()
+	})
+	return &testEnv{
+		mr:    mr,
+		rdb:   rdb,
+		clock: &fakeClock{now: time.Unix(1700000000, 0)},
+	}
+}
+
+// advance 同时推进时钟和 redis 中 key 的过期时间
+func (e *testEnv) advance(d time.Duration) {
+	e.clock.Advance(d)
+	e.mr.FastForward(d)
+}
+
+// newRateLimiter 使用假时钟创建限流器
+func (e *testEnv) newRateLimiter(t testing.TB, opts ...Option) *RateLimiter {
+	r, err := New(e.rdb, append([]Option{WithClock(e.clock.Now)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// newTokenBucket 使用假时钟创建令牌桶
+func (e *testEnv) newTokenBucket(t testing.TB, rate float64, burst int64, opts ...TokenBucketOption) *TokenBucket {
+	b, err := NewTokenBucket(e.rdb, rate, burst, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.now = e.clock.Now
+	if b.warmUp != nil {
+		b.warmUp.start = b.now()
+	}
+	return b
+}
@@ -0,0 +1,69 @@
+package rate_limiter
+
+import (
+	"net/http"
+)
+
+// HTTPHandler 限流或者出错时的回调，负责写入响应
+type HTTPHandler func(w http.ResponseWriter, req *http.Request, err error)
+
+func defaultHTTPOnLimited(w http.ResponseWriter, _ *http.Request, err error) {
+	http.Error(w, err.Error(), rejectedStatus(err))
+}
+
+func defaultHTTPOnError(w http.ResponseWriter, _ *http.Request, _ error) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// BuildHTTPMiddleware 标准库 net/http 的中间件，行为和 BuildServerMiddleware 一致。
+// Echo 可以通过 echo.WrapMiddleware，Fiber 可以通过 adaptor.HTTPMiddleware 使用
+func (r *RateLimiter) BuildHTTPMiddleware() func(next http.Handler) http.Handler {
+	onLimited, onError := r.httpOnLimited, r.httpOnError
+	if onLimited == nil {
+		onLimited = defaultHTTPOnLimited
+	}
+	if onError == nil {
+		onError = defaultHTTPOnError
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			newRequest := func() *Request {
+				return newHTTPRequest(req, r.httpClientIP(req))
+			}
+
+			var (
+				key string
+				err error
+			)
+			outcome := len(r.penalizeHTTPStatus) > 0
+			if outcome {
+				key, err = r.checkOutcome(req.Context(), req.RequestURI, newRequest)
+			} else {
+				err = r.limit(req.Context(), req.RequestURI, newRequest)
+			}
+			switch {
+			case err == nil && outcome:
+				rec := &statusRecorder{ResponseWriter: w}
+				next.ServeHTTP(rec, req)
+				if r.penalizeHTTP(rec.status) {
+					r.penalize(req.Context(), key)
+				}
+			case err == nil:
+				next.ServeHTTP(w, req)
+			case isRejected(err):
+				onLimited(w, req, err)
+			default:
+				onError(w, req, err)
+			}
+		})
+	}
+}
+
+// rejectedStatus 拒绝请求时默认的 HTTP 状态码
+func rejectedStatus(err error) int {
+	if err == DeniedErr {
+		return http.StatusForbidden
+	}
+	return http.StatusTooManyRequests
+}
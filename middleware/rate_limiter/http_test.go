@@ -0,0 +1,113 @@
+package rate_limiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_BuildHTTPMiddleware(t *testing.T) {
+	middleware := NewRateLimiter(unreachableRedis(), "rate_limiter", time.Second, 1,
+		WithAllowlist(MatchCIDR(netip.MustParsePrefix("10.0.0.0/8"))),
+		WithDenylist(MatchMethod("/admin/*")),
+	).BuildHTTPMiddleware()
+
+	var count int
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		count++
+	}))
+
+	testCases := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		forwarded  string
+		wantCode   int
+		wantCount  int
+	}{
+		{name: "allowlist", path: "/", remoteAddr: "10.0.0.1:1234", wantCode: http.StatusOK, wantCount: 1},
+		{name: "denylist", path: "/admin/users", remoteAddr: "10.0.0.1:1234", wantCode: http.StatusForbidden, wantCount: 1},
+		// 访问 redis 失败
+		{name: "redis error", path: "/", remoteAddr: "192.168.0.1:1234", wantCode: http.StatusInternalServerError, wantCount: 1},
+		// 不信任代理头
+		{name: "spoofed", path: "/", remoteAddr: "203.0.113.9:1234", forwarded: "10.1.1.1", wantCode: http.StatusInternalServerError, wantCount: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+			assert.Equal(t, tc.wantCount, count)
+		})
+	}
+}
+
+func TestRateLimiter_httpClientIP(t *testing.T) {
+	r := NewRateLimiter(unreachableRedis(), "rate_limiter", time.Second, 1, WithTrustProxyHeaders())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.0.1:1234"
+	assert.Equal(t, "192.168.0.1", r.httpClientIP(req))
+
+	// 只信任直接相连的代理追加的最后一个地址
+	req.Header.Add("X-Forwarded-For", "10.1.1.1, 203.0.113.9")
+	assert.Equal(t, "203.0.113.9", r.httpClientIP(req))
+	req.Header.Add("X-Forwarded-For", "198.51.100.7")
+	assert.Equal(t, "198.51.100.7", r.httpClientIP(req))
+}
+
+func TestRateLimiter_BuildHTTPMiddleware_Limit(t *testing.T) {
+	env := newTestEnv(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	serve := func(handler http.Handler) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	t.Run("default", func(t *testing.T) {
+		env.mr.FlushAll()
+		handler := env.newRateLimiter(t, WithWindow(time.Second, 1)).BuildHTTPMiddleware()(next)
+		assert.Equal(t, http.StatusOK, serve(handler).Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(handler).Code)
+
+		// 窗口滑过之后恢复
+		env.advance(time.Second)
+		assert.Equal(t, http.StatusOK, serve(handler).Code)
+	})
+
+	t.Run("on limited", func(t *testing.T) {
+		env.mr.FlushAll()
+		handler := env.newRateLimiter(t, WithWindow(time.Second, 1),
+			WithHTTPOnLimited(func(w http.ResponseWriter, req *http.Request, err error) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			}),
+		).BuildHTTPMiddleware()(next)
+		assert.Equal(t, http.StatusOK, serve(handler).Code)
+		w := serve(handler)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.Equal(t, LimitedErr.Error()+"\n", w.Body.String())
+	})
+
+	t.Run("on error", func(t *testing.T) {
+		var gotErr error
+		handler := NewRateLimiter(unreachableRedis(), "rate_limiter", time.Second, 1,
+			WithHTTPOnError(func(w http.ResponseWriter, req *http.Request, err error) {
+				gotErr = err
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}),
+		).BuildHTTPMiddleware()(next)
+		assert.Equal(t, http.StatusServiceUnavailable, serve(handler).Code)
+		assert.Error(t, gotErr)
+	})
+}
@@ -0,0 +1,64 @@
+package rate_limiter
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Limit 框架无关的限流入口，gin、gRPC 和 net/http 中间件都基于它实现，其它框架可以直接调用。
+// 返回 nil 表示放行，LimitedErr、QuotaExceededErr 或 DeniedErr 表示拒绝，其它错误来自 redis。
+// req 用于黑白名单匹配，没有配置名单时可以传 nil
+func (r *RateLimiter) Limit(ctx context.Context, key string, req *Request) error {
+	return r.limit(ctx, key, func() *Request {
+		return req
+	})
+}
+
+func (r *RateLimiter) limit(ctx context.Context, key string, newRequest func() *Request) error {
+	_, err := r.limitN(ctx, key, newRequest, 1)
+	return err
+}
+
+// limitN 只有配置了黑白名单或者 KeyFunc 时才会构造 Request。
+// 返回实际限流的 key，命中白名单时返回空字符串
+func (r *RateLimiter) limitN(ctx context.Context, key string, newRequest func() *Request, cost uint64) (string, error) {
+	if r.needRequest() {
+		if req := newRequest(); req != nil {
+			switch r.precheck(ctx, req) {
+			case verdictDeny:
+				return "", DeniedErr
+			case verdictAllow:
+				return "", nil
+			}
+			if r.keyFunc != nil {
+				key = r.keyFunc(ctx, req)
+			}
+		}
+	}
+
+	// lua 脚本返回 {是否放行, 窗口内的请求数}，allowN 据此记录指标并判断是否限流
+	allow, err := r.allowN(ctx, key, cost)
+	if err != nil {
+		// 影子模式始终放行，redis 出错时也一样
+		if r.failOpen || r.shadow {
+			r.logger.WarnContext(ctx, "rate limiter backend failed, request let through",
+				slog.String("rule", r.name),
+				slog.Any("error", err))
+			return "", nil
+		}
+		return "", err
+	}
+	if !allow {
+		return "", r.limitedErr()
+	}
+	return key, nil
+}
+
+func (r *RateLimiter) needRequest() bool {
+	return r.hasMatchers() || (r.keyFunc != nil && r.key == "")
+}
+
+// isRejected 判断是否是限流器主动拒绝，而不是 redis 出错
+func isRejected(err error) bool {
+	return err == LimitedErr || err == DeniedErr || err == QuotaExceededErr
+}
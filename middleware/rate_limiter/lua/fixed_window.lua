@@ -0,0 +1,25 @@
+local key = KEYS[1]
+
+local current = ARGV[1]
+local threshold = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+-- 本次消耗的请求数，为 0 时只查询不消耗
+local cost = tonumber(ARGV[4])
+
+-- 只保存当前窗口的编号和计数，进入新窗口时计数清零
+local values = redis.call('HMGET', key, 'window', 'count')
+local count = 0
+if values[1] == current then
+    count = tonumber(values[2])
+end
+
+-- 返回 {是否放行, 当前窗口内的请求数}
+if count + cost > threshold then
+    return {0, count}
+elseif cost == 0 then
+    return {1, count}
+else
+    redis.call('HMSET', key, 'window', current, 'count', count + cost)
+    redis.call('PEXPIRE', key, ttl)
+    return {1, count + cost}
+end
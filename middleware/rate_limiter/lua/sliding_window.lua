@@ -4,15 +4,23 @@ local start = tonumber(ARGV[1])
 local threshold = tonumber(ARGV[2])
 local now = tonumber(ARGV[3])
 local window = tonumber(ARGV[4])
+-- 本次消耗的请求数，为 0 时只查询不消耗
+local cost = tonumber(ARGV[5])
 
 redis.call('ZREMRANGEBYSCORE', key, '-inf', start)
 
 local count = redis.call('ZCOUNT', key, '-inf', '+inf')
 
-if count >= threshold then
-    return "false"
+-- 返回 {是否放行, 当前窗口内的请求数}
+if count + cost > threshold then
+    return {0, count}
+elseif cost == 0 then
+    return {1, count}
 else
-    redis.call('ZADD', key, now, now)
+    -- 同一微秒内可能有多个请求，member 需要唯一，否则会互相覆盖
+    for i = 1, cost do
+        redis.call('ZADD', key, now, ARGV[3] .. ':' .. (count + i))
+    end
     redis.call('PEXPIRE', key, window)
-    return "true"
-end
\ No newline at end of file
+    return {1, count + cost}
+end
@@ -0,0 +1,34 @@
+local key = KEYS[1]
+
+local current = ARGV[1]
+local previous = ARGV[2]
+local threshold = tonumber(ARGV[3])
+local weight = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+-- 本次消耗的请求数，为 0 时只查询不消耗
+local cost = tonumber(ARGV[6])
+
+-- 保存当前窗口的编号、计数以及上一个窗口的计数
+local values = redis.call('HMGET', key, 'window', 'count', 'previous')
+local currentCount = 0
+local previousCount = 0
+if values[1] == current then
+    currentCount = tonumber(values[2])
+    previousCount = tonumber(values[3]) or 0
+elseif values[1] == previous then
+    previousCount = tonumber(values[2])
+end
+
+-- 上一个窗口按照和滑动窗口重叠的比例计入，假设请求在窗口内均匀分布
+local count = math.floor(previousCount * weight) + currentCount
+
+-- 返回 {是否放行, 估算的滑动窗口内的请求数}
+if count + cost > threshold then
+    return {0, count}
+elseif cost == 0 then
+    return {1, count}
+else
+    redis.call('HMSET', key, 'window', current, 'count', currentCount + cost, 'previous', previousCount)
+    redis.call('PEXPIRE', key, ttl)
+    return {1, count + cost}
+end
@@ -0,0 +1,29 @@
+local key = KEYS[1]
+
+local now = tonumber(ARGV[1])
+-- 每微秒补充的令牌数
+local rate = tonumber(ARGV[2])
+local capacity = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+
+local values = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(values[1]) or capacity
+local ts = tonumber(values[2]) or now
+
+-- 多个实例之间的时钟可能有偏差，时间只往前走
+if now > ts then
+    tokens = math.min(capacity, tokens + (now - ts) * rate)
+    ts = now
+end
+
+-- 预占令牌，令牌不足时余额为负数，调用方需要等待补齐之后再继续
+tokens = tokens - cost
+redis.call('HMSET', key, 'tokens', tokens, 'ts', string.format('%d', ts))
+-- 补满之后的状态和不存在一致，可以过期
+redis.call('PEXPIRE', key, math.ceil((capacity - tokens) / rate / 1000) + 1)
+
+-- 返回需要等待的微秒数
+if tokens >= 0 then
+    return 0
+end
+return math.ceil(-tokens / rate)
@@ -0,0 +1,211 @@
+package rate_limiter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Request 限流前可以拿到的请求信息，用于名单匹配
+type Request struct {
+	Method string      // HTTP 为请求路径，gRPC 为完整的方法名
+	IP     netip.Addr  // 客户端 IP
+	Header http.Header // HTTP header，gRPC 时为 metadata
+	Peers  []string    // 对端身份，取自 TLS 证书的 CommonName、DNS 和 URI SAN
+}
+
+type Matcher interface {
+	Match(req *Request) bool
+}
+
+type MatcherFunc func(req *Request) bool
+
+func (f MatcherFunc) Match(req *Request) bool {
+	return f(req)
+}
+
+// MatchCIDR 客户端 IP 落在任意一个网段内
+func MatchCIDR(prefixes ...netip.Prefix) Matcher {
+	return MatcherFunc(func(req *Request) bool {
+		if !req.IP.IsValid() {
+			return false
+		}
+		ip := req.IP.Unmap()
+		for _, prefix := range prefixes {
+			if prefix.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// MatchHeader header 的值等于任意一个 values，values 为空时只要求 header 存在
+func MatchHeader(name string, values ...string) Matcher {
+	return MatcherFunc(func(req *Request) bool {
+		got := req.Header.Values(name)
+		if len(values) == 0 {
+			return len(got) > 0
+		}
+		for _, g := range got {
+			for _, v := range values {
+				if g == v {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// MatchPeer 对端身份等于任意一个 ids，例如 "admin.internal" 或者 "spiffe://cluster/ns/ops/sa/admin"
+func MatchPeer(ids ...string) Matcher {
+	return MatcherFunc(func(req *Request) bool {
+		for _, p := range req.Peers {
+			for _, id := range ids {
+				if p == id {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// MatchMethod 方法名匹配任意一个 path.Match 格式的模式，例如 "/health*" 或 "/grpc.health.v1.Health/*"
+func MatchMethod(patterns ...string) Matcher {
+	return MatcherFunc(func(req *Request) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, req.Method); ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+type verdict int
+
+const (
+	verdictLimit verdict = iota // 正常走限流
+	verdictAllow                // 命中白名单，跳过限流
+	verdictDeny                 // 命中黑名单，直接拒绝
+)
+
+func (r *RateLimiter) hasMatchers() bool {
+	return len(r.allowlist) > 0 || len(r.denylist) > 0
+}
+
+// precheck 在访问 redis 之前检查黑白名单，黑名单优先。
+// 影子模式下命中黑名单只记录本该被拒绝的请求，依旧放行
+func (r *RateLimiter) precheck(ctx context.Context, req *Request) verdict {
+	for _, m := range r.denylist {
+		if !m.Match(req) {
+			continue
+		}
+		if r.shadow {
+			r.metrics.incDecision(r.name, r.keyClass(), decisionShadowRejected)
+			r.logger.WarnContext(ctx, "rate limiter would deny request",
+				slog.String("rule", r.name))
+			return verdictAllow
+		}
+		r.metrics.incDecision(r.name, r.keyClass(), decisionDenied)
+		return verdictDeny
+	}
+	for _, m := range r.allowlist {
+		if m.Match(req) {
+			r.metrics.incDecision(r.name, r.keyClass(), decisionBypassed)
+			return verdictAllow
+		}
+	}
+	return verdictLimit
+}
+
+// remoteIP 对端地址，不受代理头影响
+func remoteIP(req *http.Request) string {
+	host, _, _ := net.SplitHostPort(req.RemoteAddr)
+	return host
+}
+
+func (r *RateLimiter) ginClientIP(c *gin.Context) string {
+	if r.trustProxy {
+		return c.ClientIP()
+	}
+	return remoteIP(c.Request)
+}
+
+func (r *RateLimiter) httpClientIP(req *http.Request) string {
+	if !r.trustProxy {
+		return remoteIP(req)
+	}
+	values := req.Header.Values("X-Forwarded-For")
+	if len(values) == 0 {
+		return remoteIP(req)
+	}
+	// 最后一个地址由直接相连的代理追加，前面的都可能是客户端伪造的
+	parts := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
+
+func newHTTPRequest(req *http.Request, clientIP string) *Request {
+	ip, _ := netip.ParseAddr(clientIP)
+	return &Request{
+		Method: req.URL.Path,
+		IP:     ip,
+		Header: req.Header,
+		Peers:  tlsPeers(req.TLS),
+	}
+}
+
+func newGrpcRequest(ctx context.Context, fullMethod string) *Request {
+	req := &Request{
+		Method: fullMethod,
+		Header: http.Header{},
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for k, vs := range md {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr, ok := p.Addr.(*net.TCPAddr); ok {
+			req.IP = addr.AddrPort().Addr()
+		}
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			req.Peers = tlsPeers(&info.State)
+		}
+	}
+	return req
+}
+
+func tlsPeers(state *tls.ConnectionState) []string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return certIdentities(state.PeerCertificates[0])
+}
+
+func certIdentities(cert *x509.Certificate) []string {
+	ids := make([]string, 0, 1+len(cert.DNSNames)+len(cert.URIs))
+	if cert.Subject.CommonName != "" {
+		ids = append(ids, cert.Subject.CommonName)
+	}
+	ids = append(ids, cert.DNSNames...)
+	for _, u := range cert.URIs {
+		ids = append(ids, u.String())
+	}
+	return ids
+}
@@ -0,0 +1,133 @@
+package rate_limiter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestMatcher(t *testing.T) {
+	req := &Request{
+		Method: "/grpc.health.v1.Health/Check",
+		IP:     netip.MustParseAddr("::ffff:10.0.0.8"),
+		Header: http.Header{"X-Internal-Token": []string{"ops"}},
+		Peers:  []string{"admin.internal"},
+	}
+
+	testCases := []struct {
+		name    string
+		matcher Matcher
+		want    bool
+	}{
+		{name: "cidr", matcher: MatchCIDR(netip.MustParsePrefix("10.0.0.0/8")), want: true},
+		{name: "cidr miss", matcher: MatchCIDR(netip.MustParsePrefix("192.168.0.0/16")), want: false},
+		{name: "header value", matcher: MatchHeader("x-internal-token", "ops"), want: true},
+		{name: "header value miss", matcher: MatchHeader("X-Internal-Token", "dev"), want: false},
+		{name: "header present", matcher: MatchHeader("X-Internal-Token"), want: true},
+		{name: "peer", matcher: MatchPeer("admin.internal"), want: true},
+		{name: "peer miss", matcher: MatchPeer("customer"), want: false},
+		{name: "method", matcher: MatchMethod("/grpc.health.v1.Health/*"), want: true},
+		{name: "method miss", matcher: MatchMethod("/user.UserService/*"), want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.matcher.Match(req))
+		})
+	}
+}
+
+// 名单命中时不会访问 redis，这里使用一个不可用的 redis 验证
+func unreachableRedis() redis.Cmdable {
+	return redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		MaxRetries:  -1,
+		DialTimeout: time.Second,
+	})
+}
+
+func TestRateLimiter_BuildServerMiddleware_Matcher(t *testing.T) {
+	middleware := NewRateLimiter(unreachableRedis(), "rate_limiter", time.Second, 1,
+		WithAllowlist(MatchMethod("/health")),
+		WithDenylist(MatchHeader("X-Banned")),
+	).BuildServerMiddleware()
+
+	var count int
+	router := gin.New()
+	router.Use(middleware)
+	router.GET("/*path", func(c *gin.Context) {
+		count++
+	})
+
+	w := PerformRequest(router, http.MethodGet, "/health")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, count)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("X-Banned", "1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 1, count)
+}
+
+func TestRateLimiter_BuildServerInterceptor_Matcher(t *testing.T) {
+	interceptor := NewRateLimiter(unreachableRedis(), "rate_limiter", time.Second, 1,
+		WithAllowlist(MatchCIDR(netip.MustParsePrefix("10.0.0.0/8"))),
+		WithDenylist(MatchHeader("x-banned")),
+	).BuildServerInterceptor()
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 50051},
+	})
+
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-banned", "1"))
+	resp, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"}, handler)
+	assert.Equal(t, DeniedErr, err)
+	assert.Nil(t, resp)
+}
+
+func TestRateLimiter_BuildServerMiddleware_SpoofedHeader(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []Option
+		wantCode int
+	}{
+		// 默认不信任代理头，伪造的 X-Forwarded-For 不能跳过限流
+		{name: "spoofed", wantCode: http.StatusInternalServerError},
+		{name: "trust proxy headers", opts: []Option{WithTrustProxyHeaders()}, wantCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]Option{WithAllowlist(MatchCIDR(netip.MustParsePrefix("10.0.0.0/8")))}, tc.opts...)
+			router := gin.New()
+			router.Use(NewRateLimiter(unreachableRedis(), "rate_limiter", time.Second, 1, opts...).BuildServerMiddleware())
+			router.GET("/", func(c *gin.Context) {})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.9:1234"
+			req.Header.Set("X-Forwarded-For", "10.1.1.1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.wantCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package rate_limiter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	decisionAllowed  = "allowed"
+	decisionRejected = "rejected"
+	decisionErrored  = "errored"
+	// decisionShadowRejected 影子模式下本该被拒绝，但实际放行的请求
+	decisionShadowRejected = "shadow_rejected"
+	// decisionBypassed 命中白名单，没有经过限流
+	decisionBypassed = "bypassed"
+	// decisionDenied 命中黑名单，直接拒绝
+	decisionDenied = "denied"
+	// decisionPenalized 按照结果计数时，请求失败被计入窗口
+	decisionPenalized = "penalized"
+
+	// keyClassFixed 所有请求共享构造时指定的 key
+	keyClassFixed = "fixed"
+	// keyClassRequest key 来自请求本身，例如 URI 或者 gRPC 方法名
+	keyClassRequest = "request"
+	// keyClassCustom key 由 WithKeyFunc 生成
+	keyClassCustom = "custom"
+)
+
+// Metrics 限流器的监控指标，实现了 prometheus.Collector，由使用方自行注册
+type Metrics struct {
+	decisions *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	usage     *prometheus.GaugeVec
+}
+
+var _ prometheus.Collector = (*Metrics)(nil)
+
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		decisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rate_limiter",
+			Name:      "decisions_total",
+			Help:      "Number of rate limit decisions, partitioned by rule, key class and decision.",
+		}, []string{"rule", "key_class", "decision"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rate_limiter",
+			Name:      "redis_script_duration_seconds",
+			Help:      "Latency of the rate limit lua script executed on redis.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 12),
+		}, []string{"rule"}),
+		usage: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "rate_limiter",
+			Name:      "window_usage_ratio",
+			Help:      "Ratio of the last observed window count to the configured rate.",
+		}, []string{"rule"}),
+	}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.decisions.Describe(ch)
+	m.latency.Describe(ch)
+	m.usage.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.decisions.Collect(ch)
+	m.latency.Collect(ch)
+	m.usage.Collect(ch)
+}
+
+// 以下方法允许 m 为 nil，未配置监控时直接忽略
+
+func (m *Metrics) incDecision(rule, keyClass, decision string) {
+	if m == nil {
+		return
+	}
+	m.decisions.WithLabelValues(rule, keyClass, decision).Inc()
+}
+
+func (m *Metrics) observeLatency(rule string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.latency.WithLabelValues(rule).Observe(d.Seconds())
+}
+
+func (m *Metrics) setUsage(rule string, usage float64) {
+	if m == nil {
+		return
+	}
+	m.usage.WithLabelValues(rule).Set(usage)
+}
@@ -0,0 +1,73 @@
+package rate_limiter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics("test")
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(m))
+
+	m.incDecision("login", keyClassFixed, decisionAllowed)
+	m.incDecision("login", keyClassFixed, decisionAllowed)
+	m.incDecision("login", keyClassFixed, decisionRejected)
+	m.incDecision("api", keyClassRequest, decisionErrored)
+	m.observeLatency("login", time.Millisecond)
+	m.setUsage("login", 0.5)
+
+	err := testutil.CollectAndCompare(m, strings.NewReader(`
+# HELP test_rate_limiter_decisions_total Number of rate limit decisions, partitioned by rule, key class and decision.
+# TYPE test_rate_limiter_decisions_total counter
+test_rate_limiter_decisions_total{decision="allowed",key_class="fixed",rule="login"} 2
+test_rate_limiter_decisions_total{decision="errored",key_class="request",rule="api"} 1
+test_rate_limiter_decisions_total{decision="rejected",key_class="fixed",rule="login"} 1
+# HELP test_rate_limiter_window_usage_ratio Ratio of the last observed window count to the configured rate.
+# TYPE test_rate_limiter_window_usage_ratio gauge
+test_rate_limiter_window_usage_ratio{rule="login"} 0.5
+`), "test_rate_limiter_decisions_total", "test_rate_limiter_window_usage_ratio")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, testutil.CollectAndCount(m, "test_rate_limiter_redis_script_duration_seconds"))
+
+	// 未配置监控时不应该 panic
+	var nilMetrics *Metrics
+	nilMetrics.incDecision("login", keyClassFixed, decisionAllowed)
+	nilMetrics.observeLatency("login", time.Millisecond)
+	nilMetrics.setUsage("login", 1)
+}
+
+func TestRateLimiter_Metrics(t *testing.T) {
+	env := newTestEnv(t)
+	m := NewMetrics("test")
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(m))
+	r := env.newRateLimiter(t, WithKey("login"), WithWindow(time.Second, 4), WithName("login"), WithMetrics(m))
+
+	for i := 0; i < 3; i++ {
+		allow, err := r.allow(context.Background(), "/login")
+		require.NoError(t, err)
+		assert.True(t, allow)
+	}
+
+	// 每次执行 lua 脚本都记录耗时，使用率为最近一次窗口内的请求数和阈值的比例
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var count uint64
+	for _, f := range families {
+		if f.GetName() == "test_rate_limiter_redis_script_duration_seconds" {
+			require.Len(t, f.GetMetric(), 1)
+			count = f.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	assert.Equal(t, uint64(3), count)
+	assert.Equal(t, 0.75, testutil.ToFloat64(m.usage.WithLabelValues("login")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.decisions.WithLabelValues("login", keyClassFixed, decisionAllowed)))
+}
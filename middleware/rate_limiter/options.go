@@ -0,0 +1,204 @@
+package rate_limiter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+)
+
+type Option func(r *RateLimiter)
+
+// KeyFunc 根据请求生成限流的 key，例如按照客户端 IP 或者用户限流
+type KeyFunc func(ctx context.Context, req *Request) string
+
+// WithKey 所有请求共享同一个 key，也就是同一个窗口
+func WithKey(key string) Option {
+	return func(r *RateLimiter) {
+		r.key = key
+	}
+}
+
+// WithWindow 设置窗口大小和窗口内允许通过的请求数
+func WithWindow(duration time.Duration, rate uint64) Option {
+	return func(r *RateLimiter) {
+		r.duration = duration
+		r.rate = rate
+	}
+}
+
+// WithAlgorithm 设置限流算法，默认为 SlidingWindowLog
+func WithAlgorithm(a Algorithm) Option {
+	return func(r *RateLimiter) {
+		r.algorithm = a
+	}
+}
+
+// WithQuota 长周期配额模式，每个周期内最多允许 limit 个请求，周期按照 loc 时区的日历对齐，
+// 计数保留到周期结束。配额耗尽时返回 QuotaExceededErr。开启后 WithWindow 和 WithAlgorithm 不生效
+func WithQuota(period QuotaPeriod, limit uint64, loc *time.Location) Option {
+	return func(r *RateLimiter) {
+		r.quota = period
+		r.rate = limit
+		r.location = loc
+	}
+}
+
+// WithPenalizeHTTPStatus gin 和 net/http 中间件只在响应状态码为 statuses 之一时计数，
+// 窗口内失败次数达到阈值之后拒绝后续所有请求，用于登录、验证码等接口。
+// statuses 为空时默认统计 401 和 403
+func WithPenalizeHTTPStatus(statuses ...int) Option {
+	return func(r *RateLimiter) {
+		if len(statuses) == 0 {
+			statuses = defaultPenalizeHTTPStatus
+		}
+		r.penalizeHTTPStatus = statuses
+	}
+}
+
+// WithPenalizeGrpcCodes gRPC 拦截器只在返回的错误码为 codes 之一时计数，
+// codes 为空时默认统计 Unauthenticated 和 PermissionDenied
+func WithPenalizeGrpcCodes(codes ...codes.Code) Option {
+	return func(r *RateLimiter) {
+		if len(codes) == 0 {
+			codes = defaultPenalizeGrpcCodes
+		}
+		r.penalizeGrpcCodes = codes
+	}
+}
+
+// WithWarmUp 预热，限流器创建之后的 period 内，阈值从 rate * fraction 线性增长到 rate。
+// 每个实例从自身启动开始计算
+func WithWarmUp(period time.Duration, fraction float64) Option {
+	return func(r *RateLimiter) {
+		r.warmUp = &warmUp{period: period, fraction: fraction}
+	}
+}
+
+// WithKeyFunc 自定义限流的 key，默认使用 HTTP 的 URI 或者 gRPC 的方法名。
+// 通过 WithKey 指定了固定 key 时不生效
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(r *RateLimiter) {
+		r.keyFunc = fn
+	}
+}
+
+// WithFailOpen redis 出错时放行请求，默认拒绝
+func WithFailOpen() Option {
+	return func(r *RateLimiter) {
+		r.failOpen = true
+	}
+}
+
+// WithClock 设置时钟，默认使用 time.Now
+func WithClock(now func() time.Time) Option {
+	return func(r *RateLimiter) {
+		r.now = now
+	}
+}
+
+// WithName 设置限流规则的名字，用于监控指标的 rule 标签
+func WithName(name string) Option {
+	return func(r *RateLimiter) {
+		r.name = name
+	}
+}
+
+// WithMetrics 设置监控指标，nil 表示不采集
+func WithMetrics(m *Metrics) Option {
+	return func(r *RateLimiter) {
+		r.metrics = m
+	}
+}
+
+// WithTracerProvider 设置链路追踪使用的 TracerProvider，默认使用 otel 全局的
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(r *RateLimiter) {
+		r.tracerProvider = tp
+	}
+}
+
+// WithShadow 开启影子模式：依旧执行限流判断、采集指标并记录本该被拒绝的请求，但是始终放行，
+// 命中黑名单和 redis 出错时也放行。
+// 用于上线新规则前评估影响范围
+func WithShadow() Option {
+	return func(r *RateLimiter) {
+		r.shadow = true
+	}
+}
+
+// WithLogger 设置日志，默认使用 slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *RateLimiter) {
+		r.logger = logger
+	}
+}
+
+// WithAllowlist 命中任意一个 matcher 的请求跳过限流，不会访问 redis
+func WithAllowlist(matchers ...Matcher) Option {
+	return func(r *RateLimiter) {
+		r.allowlist = append(r.allowlist, matchers...)
+	}
+}
+
+// WithDenylist 命中任意一个 matcher 的请求直接拒绝，优先于白名单
+func WithDenylist(matchers ...Matcher) Option {
+	return func(r *RateLimiter) {
+		r.denylist = append(r.denylist, matchers...)
+	}
+}
+
+// WithTrustProxyHeaders 名单匹配时从代理头中读取客户端 IP，默认只使用对端地址，因为代理头可以被客户端随意伪造。
+// gin 中间件使用 c.ClientIP()，需要通过 engine.SetTrustedProxies 配置可信的代理；
+// net/http 中间件使用 X-Forwarded-For 中最后一个地址，即直接相连的代理追加的地址。
+// 只在前面有可信代理时开启
+func WithTrustProxyHeaders() Option {
+	return func(r *RateLimiter) {
+		r.trustProxy = true
+	}
+}
+
+// WithGinOnLimited 自定义 gin 中间件拒绝请求时的处理，err 为 LimitedErr、QuotaExceededErr 或者 DeniedErr。
+// 默认返回 429 或 403，没有 body
+func WithGinOnLimited(fn GinHandler) Option {
+	return func(r *RateLimiter) {
+		r.ginOnLimited = fn
+	}
+}
+
+// WithGinOnError 自定义 gin 中间件访问 redis 出错时的处理，默认返回 500
+func WithGinOnError(fn GinHandler) Option {
+	return func(r *RateLimiter) {
+		r.ginOnError = fn
+	}
+}
+
+// WithGrpcOnLimited 自定义 gRPC 拦截器拒绝请求时返回的错误，默认直接返回拒绝的原因
+func WithGrpcOnLimited(fn GrpcHandler) Option {
+	return func(r *RateLimiter) {
+		r.grpcOnLimited = fn
+	}
+}
+
+// WithGrpcOnError 自定义 gRPC 拦截器访问 redis 出错时返回的错误，默认直接返回该错误
+func WithGrpcOnError(fn GrpcHandler) Option {
+	return func(r *RateLimiter) {
+		r.grpcOnError = fn
+	}
+}
+
+// WithHTTPOnLimited 自定义 net/http 中间件拒绝请求时的处理，默认返回 429 或 403
+func WithHTTPOnLimited(fn HTTPHandler) Option {
+	return func(r *RateLimiter) {
+		r.httpOnLimited = fn
+	}
+}
+
+// WithHTTPOnError 自定义 net/http 中间件访问 redis 出错时的处理，默认返回 500
+func WithHTTPOnError(fn HTTPHandler) Option {
+	return func(r *RateLimiter) {
+		r.httpOnError = fn
+	}
+}
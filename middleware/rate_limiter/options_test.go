@@ -0,0 +1,93 @@
+package rate_limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "ok", opts: []Option{WithWindow(time.Second, 10)}},
+		{name: "no window", wantErr: true},
+		{name: "zero rate", opts: []Option{WithWindow(time.Second, 0)}, wantErr: true},
+		{name: "zero duration", opts: []Option{WithWindow(0, 10)}, wantErr: true},
+		{name: "negative duration", opts: []Option{WithWindow(-time.Second, 10)}, wantErr: true},
+		{name: "nil clock", opts: []Option{WithWindow(time.Second, 10), WithClock(nil)}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := New(unreachableRedis(), tc.opts...)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, InvalidConfigErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, defaultRuleName, r.name)
+		})
+	}
+
+	_, err := New(nil, WithWindow(time.Second, 10))
+	assert.ErrorIs(t, err, InvalidConfigErr)
+}
+
+func TestRateLimiter_FailOpen(t *testing.T) {
+	r, err := New(unreachableRedis(), WithWindow(time.Second, 1), WithName("login"))
+	require.NoError(t, err)
+	err = r.Limit(context.Background(), "/login", nil)
+	assert.Error(t, err)
+	assert.False(t, isRejected(err))
+
+	r, err = New(unreachableRedis(), WithWindow(time.Second, 1), WithFailOpen())
+	require.NoError(t, err)
+	assert.NoError(t, r.Limit(context.Background(), "/login", nil))
+}
+
+func TestRateLimiter_KeyFunc(t *testing.T) {
+	env := newTestEnv(t)
+	byIP := WithKeyFunc(func(ctx context.Context, req *Request) string {
+		return req.IP.String()
+	})
+	serve := func(handler http.Handler, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	newRouter := func(r *RateLimiter) *gin.Engine {
+		router := gin.New()
+		router.Use(r.BuildServerMiddleware())
+		router.GET("/", func(c *gin.Context) {})
+		return router
+	}
+
+	t.Run("per client", func(t *testing.T) {
+		env.mr.FlushAll()
+		router := newRouter(env.newRateLimiter(t, WithWindow(time.Second, 1), byIP))
+		assert.Equal(t, http.StatusOK, serve(router, "10.0.0.1:1234"))
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, "10.0.0.1:5678"))
+		// 不同的客户端使用各自的窗口
+		assert.Equal(t, http.StatusOK, serve(router, "10.0.0.2:1234"))
+		assert.ElementsMatch(t, []string{"10.0.0.1", "10.0.0.2"}, env.mr.Keys())
+	})
+
+	t.Run("fixed key", func(t *testing.T) {
+		env.mr.FlushAll()
+		// WithKey 优先，所有客户端共享同一个窗口
+		router := newRouter(env.newRateLimiter(t, WithWindow(time.Second, 1), byIP, WithKey("global")))
+		assert.Equal(t, http.StatusOK, serve(router, "10.0.0.1:1234"))
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, "10.0.0.2:1234"))
+		assert.Equal(t, []string{"global"}, env.mr.Keys())
+	})
+}
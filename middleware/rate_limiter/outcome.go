@@ -0,0 +1,71 @@
+package rate_limiter
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+)
+
+// 按照结果计数时，默认只统计鉴权失败
+var (
+	defaultPenalizeHTTPStatus = []int{http.StatusUnauthorized, http.StatusForbidden}
+	defaultPenalizeGrpcCodes  = []codes.Code{codes.Unauthenticated, codes.PermissionDenied}
+)
+
+// checkOutcome 按照结果计数时，进入 handler 之前只检查是否已经超过阈值，不消耗。
+// 返回实际限流的 key，为空表示不需要在 handler 之后计数
+func (r *RateLimiter) checkOutcome(ctx context.Context, key string, newRequest func() *Request) (string, error) {
+	return r.limitN(ctx, key, newRequest, 0)
+}
+
+// penalize handler 失败之后计数，达到阈值之后 checkOutcome 会拒绝后续请求
+func (r *RateLimiter) penalize(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	key = r.storeKey(key)
+	if _, err := r.eval(ctx, key, 1); err != nil {
+		r.metrics.incDecision(r.name, r.keyClass(), decisionErrored)
+		r.logger.WarnContext(ctx, "rate limiter failed to penalize request",
+			slog.String("rule", r.name),
+			slog.String("key_hash", hashKey(key)),
+			slog.Any("error", err))
+		return
+	}
+	r.metrics.incDecision(r.name, r.keyClass(), decisionPenalized)
+}
+
+func (r *RateLimiter) penalizeHTTP(status int) bool {
+	return slices.Contains(r.penalizeHTTPStatus, status)
+}
+
+func (r *RateLimiter) penalizeGrpc(code codes.Code) bool {
+	return slices.Contains(r.penalizeGrpcCodes, code)
+}
+
+// statusRecorder 记录 handler 写入的状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,73 @@
+package rate_limiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimiter_BuildServerMiddleware_Penalize(t *testing.T) {
+	r := newTestEnv(t).newRateLimiter(t, WithWindow(time.Minute, 2), WithPenalizeHTTPStatus())
+
+	var count int
+	router := gin.New()
+	router.Use(r.BuildServerMiddleware())
+	router.GET("/login", func(c *gin.Context) {
+		count++
+		if c.GetHeader("X-Password") != "123456" {
+			c.Status(http.StatusUnauthorized)
+		}
+	})
+
+	login := func(password string) int {
+		req := httptest.NewRequest(http.MethodGet, "/login", nil)
+		req.Header.Set("X-Password", password)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 成功的请求不计数
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, login("123456"))
+	}
+
+	// 失败两次之后锁定，正确的密码也会被拒绝
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("wrong"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, login("123456"))
+	assert.Equal(t, 5, count)
+}
+
+func TestRateLimiter_BuildServerInterceptor_Penalize(t *testing.T) {
+	interceptor := newTestEnv(t).newRateLimiter(t, WithWindow(time.Minute, 1),
+		WithPenalizeGrpcCodes(codes.Unauthenticated)).BuildServerInterceptor()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Login"}
+	ok := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+	unauthenticated := func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.Unauthenticated, "wrong password")
+	}
+
+	resp, err := interceptor(context.Background(), nil, info, ok)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = interceptor(context.Background(), nil, info, unauthenticated)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = interceptor(context.Background(), nil, info, ok)
+	assert.Equal(t, LimitedErr, err)
+}
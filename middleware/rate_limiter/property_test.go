@@ -0,0 +1,176 @@
+package rate_limiter
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// scenario 随机生成的流量：每一轮并发发起若干请求，然后时钟前进一段随机的时间
+type scenario struct {
+	Rate     uint64
+	Window   time.Duration
+	Rounds   []round
+	Parallel int
+}
+
+type round struct {
+	Requests int
+	Advance  time.Duration
+}
+
+func (scenario) Generate(rand *rand.Rand, _ int) reflect.Value {
+	s := scenario{
+		Rate:     uint64(rand.Intn(20) + 1),
+		Window:   time.Duration(rand.Intn(2000)+100) * time.Millisecond,
+		Parallel: rand.Intn(8) + 1,
+	}
+	s.Rounds = make([]round, rand.Intn(30)+10)
+	for i := range s.Rounds {
+		s.Rounds[i] = round{
+			Requests: rand.Intn(int(s.Rate) * 2),
+			Advance:  time.Duration(rand.Int63n(int64(s.Window) / 2)),
+		}
+	}
+	return reflect.ValueOf(s)
+}
+
+// run 执行场景，返回被放行的请求的时间
+func (s scenario) run(t *testing.T, algorithm Algorithm) []time.Time {
+	env := newTestEnv(t)
+	r := env.newRateLimiter(t, WithKey("key"), WithWindow(s.Window, s.Rate), WithAlgorithm(algorithm))
+
+	var (
+		mu      sync.Mutex
+		allowed []time.Time
+	)
+	for _, rd := range s.Rounds {
+		var wg sync.WaitGroup
+		requests := make(chan struct{}, rd.Requests)
+		for i := 0; i < rd.Requests; i++ {
+			requests <- struct{}{}
+		}
+		close(requests)
+		for i := 0; i < s.Parallel; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range requests {
+					ok, err := r.allow(context.Background(), "")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if ok {
+						mu.Lock()
+						allowed = append(allowed, env.clock.Now())
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		env.advance(rd.Advance)
+	}
+	sort.Slice(allowed, func(i, j int) bool {
+		return allowed[i].Before(allowed[j])
+	})
+	return allowed
+}
+
+// 滑动窗口日志：任意一个长度为 window 的滑动窗口内放行的请求不超过 rate
+func TestProperty_SlidingWindowLog(t *testing.T) {
+	property := func(s scenario) bool {
+		allowed := s.run(t, SlidingWindowLog)
+		for i, end := range allowed {
+			// (end - window, end] 内的请求数
+			start := sort.Search(len(allowed), func(j int) bool {
+				return allowed[j].After(end.Add(-s.Window))
+			})
+			if uint64(i+1-start) > s.Rate {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 30}); err != nil {
+		t.Error(err)
+	}
+}
+
+// 固定窗口和滑动窗口计数：任意一个对齐的固定窗口内放行的请求不超过 rate
+func TestProperty_FixedWindow(t *testing.T) {
+	for _, algorithm := range []Algorithm{FixedWindow, SlidingWindowCounter} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			property := func(s scenario) bool {
+				counts := make(map[int64]uint64)
+				for _, at := range s.run(t, algorithm) {
+					window := at.UnixNano() / int64(s.Window)
+					counts[window]++
+					if counts[window] > s.Rate {
+						return false
+					}
+				}
+				return true
+			}
+			if err := quick.Check(property, &quick.Config{MaxCount: 30}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// 令牌桶：从开始到任意时刻，完成的令牌数不超过 burst + rate * 经过的时间
+func TestProperty_TokenBucket(t *testing.T) {
+	property := func(s scenario) bool {
+		env := newTestEnv(t)
+		rate, burst := float64(s.Rate)*float64(time.Second)/float64(s.Window), int64(s.Rate)
+		b := env.newTokenBucket(t, rate, burst)
+		start := env.clock.Now()
+
+		var (
+			mu    sync.Mutex
+			ready []time.Time
+		)
+		for _, rd := range s.Rounds {
+			var wg sync.WaitGroup
+			for i := 0; i < rd.Requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					now := env.clock.Now()
+					wait, err := b.reserve(context.Background(), "key", 1)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					ready = append(ready, now.Add(wait))
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+			env.advance(rd.Advance)
+		}
+
+		sort.Slice(ready, func(i, j int) bool {
+			return ready[i].Before(ready[j])
+		})
+		for i, at := range ready {
+			// 等待时间按照微秒向上取整，这里放宽 1 个令牌
+			limit := float64(burst) + rate*at.Sub(start).Seconds() + 1
+			if float64(i+1) > limit {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 30}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,102 @@
+package rate_limiter
+
+import (
+	"context"
+	"strings"
+)
+
+// Usage 某个 key 当前窗口的使用情况
+type Usage struct {
+	Key       string `json:"key"`
+	Count     uint64 `json:"count"`
+	Remaining uint64 `json:"remaining"`
+	Limit     uint64 `json:"limit"`
+}
+
+// Peek 查询 key 当前窗口的使用情况，不消耗请求数。key 为空时使用 WithKey 指定的 key，
+// 两者都为空时返回 EmptyKeyErr
+func (r *RateLimiter) Peek(ctx context.Context, key string) (Usage, error) {
+	key, err := r.adminKey(key)
+	if err != nil {
+		return Usage{}, err
+	}
+	res, err := r.eval(ctx, key, 0)
+	if err != nil {
+		return Usage{}, err
+	}
+	return r.usage(key, res), nil
+}
+
+// maxSlidingLogCost 滑动窗口日志每个请求都要在 lua 脚本中写入一条记录，
+// 限制单次 Consume 的数量，避免一次调用长时间阻塞 redis
+const maxSlidingLogCost = 1000
+
+// Consume 手动消耗 n 个请求数，剩余不足时不消耗并返回 LimitedErr 或 QuotaExceededErr。
+// 使用滑动窗口日志时 n 不能超过 1000，否则返回 CostTooLargeErr
+func (r *RateLimiter) Consume(ctx context.Context, key string, n uint64) (Usage, error) {
+	key, err := r.adminKey(key)
+	if err != nil {
+		return Usage{}, err
+	}
+	if r.quota == 0 && r.algorithm == SlidingWindowLog && n > maxSlidingLogCost {
+		return Usage{}, CostTooLargeErr
+	}
+	res, err := r.eval(ctx, key, n)
+	if err != nil {
+		return Usage{}, err
+	}
+	if !res.allowed {
+		return r.usage(key, res), r.limitedErr()
+	}
+	return r.usage(key, res), nil
+}
+
+// Reset 清空 key 的计数
+func (r *RateLimiter) Reset(ctx context.Context, key string) error {
+	key, err := r.adminKey(key)
+	if err != nil {
+		return err
+	}
+	return r.client.Del(ctx, key).Err()
+}
+
+// Keys 列出以 prefix 开头、当前有计数的 key。基于 SCAN 实现，不会阻塞 redis。
+// prefix 为空时只列出 WithKey 指定的 key，避免扫描整个 redis
+func (r *RateLimiter) Keys(ctx context.Context, prefix string) ([]string, error) {
+	prefix, err := r.adminKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	iter := r.client.Scan(ctx, 0, escapePattern(prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func (r *RateLimiter) adminKey(key string) (string, error) {
+	if key == "" {
+		key = r.key
+	}
+	if key == "" {
+		return "", EmptyKeyErr
+	}
+	return key, nil
+}
+
+func (r *RateLimiter) usage(key string, res result) Usage {
+	return Usage{
+		Key:       key,
+		Count:     res.count,
+		Remaining: res.remaining,
+		Limit:     res.limit,
+	}
+}
+
+var patternEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// escapePattern 转义 SCAN MATCH 中的通配符
+func escapePattern(s string) string {
+	return patternEscaper.Replace(s)
+}
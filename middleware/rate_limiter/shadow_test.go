@@ -0,0 +1,101 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestRateLimiter_Shadow(t *testing.T) {
+	env := newTestEnv(t)
+	newShadow := func() (*RateLimiter, *Metrics, *bytes.Buffer) {
+		m := NewMetrics("test")
+		var logs bytes.Buffer
+		r := env.newRateLimiter(t, WithKey("shadow"), WithWindow(time.Second, 1), WithName("shadow"),
+			WithShadow(), WithMetrics(m), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		return r, m, &logs
+	}
+
+	t.Run("gin", func(t *testing.T) {
+		env.mr.FlushAll()
+		r, m, logs := newShadow()
+		var count int
+		router := gin.New()
+		router.Use(r.BuildServerMiddleware())
+		router.GET("/", func(c *gin.Context) {
+			count++
+		})
+
+		// 超过阈值的请求依旧放行，但是被记录下来
+		for i := 0; i < 3; i++ {
+			w := PerformRequest(router, http.MethodGet, "/")
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+		assert.Equal(t, 3, count)
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.decisions.WithLabelValues("shadow", keyClassFixed, decisionAllowed)))
+		assert.Equal(t, float64(2), testutil.ToFloat64(m.decisions.WithLabelValues("shadow", keyClassFixed, decisionShadowRejected)))
+		assert.Contains(t, logs.String(), "rate limiter would reject request")
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		env.mr.FlushAll()
+		r, m, logs := newShadow()
+		interceptor := r.BuildServerInterceptor()
+		handler := func(ctx context.Context, req any) (any, error) {
+			return "ok", nil
+		}
+		for i := 0; i < 3; i++ {
+			resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"}, handler)
+			require.NoError(t, err)
+			assert.Equal(t, "ok", resp)
+		}
+		assert.Equal(t, float64(2), testutil.ToFloat64(m.decisions.WithLabelValues("shadow", keyClassFixed, decisionShadowRejected)))
+		assert.Contains(t, logs.String(), "rate limiter would reject request")
+	})
+
+	t.Run("denylist", func(t *testing.T) {
+		m := NewMetrics("test")
+		var logs bytes.Buffer
+		r := env.newRateLimiter(t, WithKey("shadow"), WithWindow(time.Second, 1), WithName("shadow"),
+			WithShadow(), WithMetrics(m), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			WithDenylist(MatchMethod("/admin/*")))
+
+		// 命中黑名单的请求同样只记录，不拒绝
+		require.NoError(t, r.Limit(context.Background(), "", &Request{Method: "/admin/users"}))
+		router := gin.New()
+		router.Use(r.BuildServerMiddleware())
+		router.GET("/admin/users", func(c *gin.Context) {})
+		w := PerformRequest(router, http.MethodGet, "/admin/users")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, float64(2), testutil.ToFloat64(m.decisions.WithLabelValues("shadow", keyClassFixed, decisionShadowRejected)))
+		assert.Equal(t, float64(0), testutil.ToFloat64(m.decisions.WithLabelValues("shadow", keyClassFixed, decisionDenied)))
+		assert.Contains(t, logs.String(), "rate limiter would deny request")
+	})
+
+	t.Run("redis error", func(t *testing.T) {
+		// 影子模式下 redis 出错同样放行
+		r := NewRateLimiter(unreachableRedis(), "shadow", time.Second, 1, WithShadow())
+		router := gin.New()
+		router.Use(r.BuildServerMiddleware())
+		router.GET("/", func(c *gin.Context) {})
+		w := PerformRequest(router, http.MethodGet, "/")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		resp, err := r.BuildServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/user.UserService/Get"},
+			func(ctx context.Context, req any) (any, error) {
+				return "ok", nil
+			})
+		require.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+	})
+}
@@ -0,0 +1,103 @@
+package rate_limiter
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed lua/token_bucket.lua
+var luaTokenBucket string
+
+// TokenBucket 基于 redis 的分布式令牌桶，多个实例共享同一个桶。
+// 和 RateLimiter 不同，令牌不足时不会拒绝，而是预占令牌并等待补齐，适合限制带宽这类需要平滑的场景
+type TokenBucket struct {
+	client redis.Cmdable
+	rate   float64 // 每秒补充的令牌数
+	burst  int64   // 桶的容量
+	now    func() time.Time
+	warmUp *warmUp // 预热，nil 表示不预热
+}
+
+type TokenBucketOption func(b *TokenBucket)
+
+// WithTokenBucketWarmUp 预热，令牌桶创建之后的 period 内，补充速率和容量从 fraction 线性增长到配置的值
+func WithTokenBucketWarmUp(period time.Duration, fraction float64) TokenBucketOption {
+	return func(b *TokenBucket) {
+		b.warmUp = &warmUp{period: period, fraction: fraction}
+	}
+}
+
+func NewTokenBucket(client redis.Cmdable, rate float64, burst int64, opts ...TokenBucketOption) (*TokenBucket, error) {
+	if client == nil {
+		return nil, errors.Wrap(InvalidConfigErr, "backend is nil")
+	}
+	if rate <= 0 {
+		return nil, errors.Wrap(InvalidConfigErr, "rate must be greater than 0")
+	}
+	if burst <= 0 {
+		return nil, errors.Wrap(InvalidConfigErr, "burst must be greater than 0")
+	}
+	b := &TokenBucket{
+		client: client,
+		rate:   rate,
+		burst:  burst,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if err := b.warmUp.validate(); err != nil {
+		return nil, err
+	}
+	if b.warmUp != nil {
+		b.warmUp.start = b.now()
+	}
+	return b, nil
+}
+
+// WaitN 消耗 n 个令牌，令牌不足时阻塞直到补齐或者 ctx 结束。
+// n 大于桶的容量时按照容量拆分成多次
+func (b *TokenBucket) WaitN(ctx context.Context, key string, n int64) error {
+	for n > 0 {
+		cost := min(n, b.burst)
+		wait, err := b.reserve(ctx, key, cost)
+		if err != nil {
+			return err
+		}
+		if err = sleep(ctx, wait); err != nil {
+			return err
+		}
+		n -= cost
+	}
+	return nil
+}
+
+// reserve 预占 n 个令牌，返回需要等待的时间
+func (b *TokenBucket) reserve(ctx context.Context, key string, n int64) (time.Duration, error) {
+	now := b.now()
+	factor := b.warmUp.factor(now)
+	wait, err := b.client.Eval(ctx, luaTokenBucket, []string{key},
+		now.UnixMicro(), b.rate*factor/float64(time.Second/time.Microsecond), scale(uint64(b.burst), factor), n).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Microsecond, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
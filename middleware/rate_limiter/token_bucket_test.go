@@ -0,0 +1,169 @@
+package rate_limiter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestTokenBucket_reserve(t *testing.T) {
+	env := newTestEnv(t)
+	b := env.newTokenBucket(t, 100, 50)
+
+	testCases := []struct {
+		name     string
+		advance  time.Duration
+		n        int64
+		wantWait time.Duration
+	}{
+		// 桶一开始是满的
+		{name: "burst", n: 50, wantWait: 0},
+		// 令牌不足时预占，等待补齐
+		{name: "empty", n: 10, wantWait: 100 * time.Millisecond},
+		{name: "debt", n: 10, wantWait: 200 * time.Millisecond},
+		// 500ms 补充 50 个令牌，抵消之前欠下的 20 个
+		{name: "refill", advance: 500 * time.Millisecond, n: 30, wantWait: 0},
+		// 补充的令牌不会超过容量
+		{name: "capacity", advance: time.Hour, n: 60, wantWait: 100 * time.Millisecond},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env.advance(tc.advance)
+			wait, err := b.reserve(context.Background(), "key", tc.n)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantWait, wait)
+		})
+	}
+
+	_, err := NewTokenBucket(env.rdb, 0, 50)
+	assert.ErrorIs(t, err, InvalidConfigErr)
+}
+
+func TestTokenBucket_Reader(t *testing.T) {
+	rdb := newTestEnv(t).rdb
+	// 每秒 10KB，一开始可以突发 1KB
+	b, err := NewTokenBucket(rdb, 10*1024, 1024)
+	require.NoError(t, err)
+
+	data := bytes.Repeat([]byte("a"), 3*1024)
+	start := time.Now()
+	got, err := io.ReadAll(b.Reader(context.Background(), "client", bytes.NewReader(data)))
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	// ctx 结束时停止等待
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = io.ReadAll(b.Reader(ctx, "client", bytes.NewReader(data)))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTokenBucket_BuildServerMiddleware(t *testing.T) {
+	rdb := newTestEnv(t).rdb
+	b, err := NewTokenBucket(rdb, 10*1024, 1024)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(b.BuildServerMiddleware(func(c *gin.Context) string {
+		return c.ClientIP()
+	}))
+	router.POST("/echo", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		require.NoError(t, err)
+		c.Data(http.StatusOK, "text/plain", body)
+	})
+
+	body := strings.Repeat("a", 2*1024)
+	start := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String())
+	// 上传和下载分别计数，各自超出突发 1KB
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+// fakeServerStream 记录发送的消息，接收时依次返回 recv 中的消息
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []any
+	recv []proto.Message
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) SendMsg(m any) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *fakeServerStream) RecvMsg(m any) error {
+	if len(s.recv) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.recv[0])
+	s.recv = s.recv[1:]
+	return nil
+}
+
+func TestTokenBucket_BuildStreamServerInterceptor(t *testing.T) {
+	env := newTestEnv(t)
+	// 每秒 100 字节，一开始可以突发 100 字节
+	b := env.newTokenBucket(t, 100, 100)
+	interceptor := b.BuildStreamServerInterceptor(func(ctx context.Context, info *grpc.StreamServerInfo) string {
+		return "client"
+	})
+	// 序列化之后正好 100 字节
+	msg := &wrapperspb.BytesValue{Value: bytes.Repeat([]byte("a"), 98)}
+	require.Equal(t, 100, proto.Size(msg))
+
+	ss := &fakeServerStream{ctx: context.Background(), recv: []proto.Message{msg, msg}}
+	err := interceptor(nil, ss, &grpc.StreamServerInfo{}, func(srv any, stream grpc.ServerStream) error {
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+		var got wrapperspb.BytesValue
+		if err := stream.RecvMsg(&got); err != nil {
+			return err
+		}
+		assert.True(t, proto.Equal(msg, &got))
+		// 不是 proto 消息时不计数
+		return stream.SendMsg("ping")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{msg, "ping"}, ss.sent)
+
+	// 收发分别消耗了整个桶，再取一个令牌需要等待 10ms
+	for _, suffix := range []string{bandwidthSendSuffix, bandwidthRecvSuffix} {
+		wait, err := b.reserve(context.Background(), "client"+suffix, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 10*time.Millisecond, wait)
+	}
+
+	// 令牌不足时等待，ctx 结束之前不会发送
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ss.ctx = ctx
+	err = interceptor(nil, ss, &grpc.StreamServerInfo{}, func(srv any, stream grpc.ServerStream) error {
+		assert.ErrorIs(t, stream.RecvMsg(&wrapperspb.BytesValue{}), context.DeadlineExceeded)
+		return stream.SendMsg(msg)
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, ss.sent, 2)
+}
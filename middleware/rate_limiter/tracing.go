@@ -0,0 +1,62 @@
+package rate_limiter
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/xiaoyeshiyu/micro-tools/middleware/rate_limiter"
+
+const (
+	attrRule           = attribute.Key("rate_limiter.rule")
+	attrKeyHash        = attribute.Key("rate_limiter.key_hash")
+	attrDecision       = attribute.Key("rate_limiter.decision")
+	attrRemaining      = attribute.Key("rate_limiter.remaining")
+	attrBackendLatency = attribute.Key("rate_limiter.backend_latency_ms")
+)
+
+func (r *RateLimiter) tracer() trace.Tracer {
+	tp := r.tracerProvider
+	if tp == nil {
+		// 没有指定时使用全局的，允许使用方在构造限流器之后再设置
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+func (r *RateLimiter) startSpan(ctx context.Context, key string) (context.Context, trace.Span) {
+	return r.tracer().Start(ctx, "rate_limiter.allow",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attrRule.String(r.name),
+			// key 可能包含用户信息，只记录哈希值
+			attrKeyHash.String(hashKey(key)),
+		))
+}
+
+func endSpan(span trace.Span, res result, decision string, err error) {
+	defer span.End()
+
+	span.SetAttributes(
+		attrDecision.String(decision),
+		attrBackendLatency.Float64(float64(res.latency.Microseconds())/1000),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(attrRemaining.Int64(int64(res.remaining)))
+}
+
+func hashKey(key string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
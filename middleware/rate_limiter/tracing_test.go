@@ -0,0 +1,91 @@
+package rate_limiter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRateLimiter_TraceRedisError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	// 连接一个不存在的 redis，模拟 redis 故障
+	rdb := redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		MaxRetries:  -1,
+		DialTimeout: time.Second,
+	})
+	limiter := NewRateLimiter(rdb, "", time.Second, 1, WithName("login"), WithTracerProvider(tp))
+
+	allow, err := limiter.allow(context.Background(), "/login")
+	require.Error(t, err)
+	assert.False(t, allow)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "rate_limiter.allow", span.Name())
+	assert.Equal(t, codes.Error, span.Status().Code)
+	require.Len(t, span.Events(), 1)
+	assert.Equal(t, "exception", span.Events()[0].Name)
+
+	attrs := attribute.NewSet(span.Attributes()...)
+	rule, _ := attrs.Value(attrRule)
+	assert.Equal(t, "login", rule.AsString())
+	keyHash, _ := attrs.Value(attrKeyHash)
+	assert.Equal(t, hashKey("/login"), keyHash.AsString())
+	decision, _ := attrs.Value(attrDecision)
+	assert.Equal(t, decisionErrored, decision.AsString())
+	assert.True(t, attrs.HasValue(attrBackendLatency))
+}
+
+func TestRateLimiter_TraceDecision(t *testing.T) {
+	env := newTestEnv(t)
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	limiter := env.newRateLimiter(t, WithKey("login"), WithWindow(time.Second, 2), WithTracerProvider(tp))
+
+	router := gin.New()
+	router.Use(limiter.BuildServerMiddleware())
+	router.GET("/login", func(c *gin.Context) {})
+
+	testCases := []struct {
+		name          string
+		wantCode      int
+		wantDecision  string
+		wantRemaining int64
+	}{
+		{name: "allowed", wantCode: http.StatusOK, wantDecision: decisionAllowed, wantRemaining: 1},
+		{name: "last", wantCode: http.StatusOK, wantDecision: decisionAllowed, wantRemaining: 0},
+		{name: "rejected", wantCode: http.StatusTooManyRequests, wantDecision: decisionRejected, wantRemaining: 0},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := PerformRequest(router, http.MethodGet, "/login")
+			assert.Equal(t, tc.wantCode, w.Code)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, i+1)
+			span := spans[i]
+			assert.Equal(t, codes.Unset, span.Status().Code)
+
+			attrs := attribute.NewSet(span.Attributes()...)
+			decision, _ := attrs.Value(attrDecision)
+			assert.Equal(t, tc.wantDecision, decision.AsString())
+			remaining, ok := attrs.Value(attrRemaining)
+			require.True(t, ok)
+			assert.Equal(t, tc.wantRemaining, remaining.AsInt64())
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package rate_limiter
+
+import (
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// warmUp 预热：从创建开始，允许的速率在 period 内从 fraction 线性增长到配置的速率，
+// 避免新规则上线或者实例启动时下游的冷缓存被打满
+type warmUp struct {
+	start    time.Time
+	period   time.Duration
+	fraction float64
+}
+
+// factor 返回当前速率相对于配置速率的比例，w 为 nil 时表示没有预热
+func (w *warmUp) factor(now time.Time) float64 {
+	if w == nil {
+		return 1
+	}
+	elapsed := now.Sub(w.start)
+	if elapsed >= w.period {
+		return 1
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return w.fraction + (1-w.fraction)*float64(elapsed)/float64(w.period)
+}
+
+func (w *warmUp) validate() error {
+	if w == nil {
+		return nil
+	}
+	if w.period <= 0 {
+		return errors.Wrap(InvalidConfigErr, "warm up period must be greater than 0")
+	}
+	if w.fraction <= 0 || w.fraction > 1 {
+		return errors.Wrap(InvalidConfigErr, "warm up fraction must be in (0, 1]")
+	}
+	return nil
+}
+
+// scale 按照比例缩放，至少为 1，避免预热期间完全不可用。
+// 减去一个很小的值，避免浮点误差导致 6.000000001 被向上取整为 7
+func scale(n uint64, factor float64) uint64 {
+	return max(uint64(math.Ceil(float64(n)*factor-1e-9)), 1)
+}
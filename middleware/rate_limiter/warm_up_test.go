@@ -0,0 +1,64 @@
+package rate_limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_WarmUp(t *testing.T) {
+	env := newTestEnv(t)
+	r := env.newRateLimiter(t, WithKey("key"), WithWindow(time.Second, 10), WithAlgorithm(FixedWindow),
+		WithWarmUp(10*time.Second, 0.2))
+
+	testCases := []struct {
+		name      string
+		advance   time.Duration
+		wantLimit uint64
+	}{
+		{name: "start", wantLimit: 2},
+		{name: "half", advance: 5 * time.Second, wantLimit: 6},
+		{name: "done", advance: 5 * time.Second, wantLimit: 10},
+		{name: "after", advance: time.Minute, wantLimit: 10},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env.advance(tc.advance)
+			usage, err := r.Peek(context.Background(), "")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantLimit, usage.Limit)
+			assert.Equal(t, int(tc.wantLimit), burst(t, r, 20))
+		})
+	}
+
+	_, err := New(env.rdb, WithWindow(time.Second, 10), WithWarmUp(time.Second, 0))
+	assert.ErrorIs(t, err, InvalidConfigErr)
+	_, err = New(env.rdb, WithWindow(time.Second, 10), WithWarmUp(0, 0.5))
+	assert.ErrorIs(t, err, InvalidConfigErr)
+}
+
+func TestTokenBucket_WarmUp(t *testing.T) {
+	env := newTestEnv(t)
+	// 预热期间容量和补充速率都只有 10%
+	b := env.newTokenBucket(t, 100, 100, WithTokenBucketWarmUp(time.Minute, 0.1))
+
+	wait, err := b.reserve(context.Background(), "key", 10)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	// 每秒只补充 10 个
+	wait, err = b.reserve(context.Background(), "key", 10)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, wait)
+
+	// 预热结束之后恢复
+	env.advance(time.Minute)
+	wait, err = b.reserve(context.Background(), "key", 100)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	_, err = NewTokenBucket(env.rdb, 100, 100, WithTokenBucketWarmUp(time.Minute, 2))
+	assert.ErrorIs(t, err, InvalidConfigErr)
+}